@@ -2,7 +2,7 @@ package cli
 
 import (
 	"fmt"
-	"go/ast"
+	"os"
 
 	lang "github.com/alligator/jqawk/src"
 )
@@ -17,7 +17,9 @@ func debugAst(prog string, rootSelectors []string) {
 			if err != nil {
 				panic(err)
 			}
-			ast.Print(nil, expr)
+			if err := lang.Fdump(os.Stdout, rsLex.FileSet(), expr); err != nil {
+				panic(err)
+			}
 		}
 	}
 	fmt.Println("program ast")
@@ -27,7 +29,9 @@ func debugAst(prog string, rootSelectors []string) {
 	if err != nil {
 		panic(err)
 	}
-	ast.Print(nil, program)
+	if err := lang.Fdump(os.Stdout, lex.FileSet(), program); err != nil {
+		panic(err)
+	}
 }
 
 func debugLex(prog string, rootSelectors []string) {
@@ -5,55 +5,337 @@ import (
 	"io"
 	"os"
 	"strings"
+	"unicode"
 
 	lang "github.com/alligator/jqawk/src"
 	"github.com/chzyer/readline"
 )
 
+// replPrompt and replContinuationPrompt are shown while waiting for, and
+// while accumulating, a multi-line BEGIN block or function declaration -
+// see the pendingSrc handling in RunRepl's loop below.
+const (
+	replPrompt             = "> "
+	replContinuationPrompt = "... "
+)
+
+// RunRepl starts an interactive line-oriented REPL for `jqawk -i`. Each line
+// is evaluated against one persistent lang.Evaluator (via EvalLine), so
+// functions and variables defined on one line are still visible on later
+// ones - unlike lang.EvalProgram, which always starts from a blank slate.
+//
+// A line containing "{" or "BEGIN" is parsed as a full program (a rule or a
+// function declaration); anything else is parsed as an expression evaluated
+// against $, and its result is printed. A program that isn't finished yet -
+// e.g. a "BEGIN {" with no closing "}" - switches the prompt to "... " and
+// keeps accumulating lines (via lang.IsUnexpectedEOF) until it parses or a
+// genuine error is hit, so multi-line blocks and functions can be entered
+// the same way they'd be written in a file.
+//
+// Commands, all starting with ":", manage $ and the session instead of
+// evaluating jqawk code:
+//
+//	:load <file>   decode <file> and bind the result to $
+//	:next          advance $ to the next element of the array loaded with :load
+//	:reset         start over with a fresh $ and no functions or variables
+//	:functions     list user-defined functions
+//	:vi, :emacs    switch the line editor's key bindings
+//	:help          show the command list
+//	:quit, :exit   exit the REPL
+//
+// If files are given (e.g. `jqawk -i data.json`), the first one is decoded
+// and bound to $ before the prompt starts, exactly as ":load" would do.
+//
+// Line editing and history go through the readline library already vendored
+// for this file rather than golang.org/x/term, which isn't used anywhere
+// else in this tree; history persists to ~/.jqawk_history across sessions.
+// Pressing tab after a "." completes against the current $'s own keys (if
+// it's an object) plus its prototype's members - see replCompleter.
 func RunRepl(version string, files []lang.InputFile, rootSelectors []string) int {
-	rl, err := readline.New("> ")
+	ev, err := lang.NewReplEvaluator(os.Stdout)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error starting REPL: %s\n", err.Error())
 		return 1
 	}
-	defer rl.Close()
+	repl := &replSession{ev: ev}
 
-	// convert each streaming input file into a buffered input file
-	bufferedFiles := make([]lang.InputFile, len(files))
-	for i, file := range files {
-		if sif, ok := file.(*lang.StreamingInputFile); ok {
-			if file.Name() == "<stdin>" {
-				fmt.Fprintln(os.Stderr, "cannot read from stdin in interactive mode")
-				return 1
-			}
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:       replPrompt,
+		HistoryFile:  replHistoryPath(),
+		AutoComplete: &replCompleter{repl: repl},
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error starting REPL: %s\n", err.Error())
+		return 1
+	}
+	defer rl.Close()
 
-			bytes, err := io.ReadAll(sif.NewReader())
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "error opening file: %s\n", err.Error())
-				return 1
-			}
-			bufferedFiles[i] = lang.NewBufferedInputFile(file.Name(), bytes)
+	if len(files) > 0 {
+		root, err := decodeInputFile(files[0], rootSelectors)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error loading %s: %s\n", files[0].Name(), err.Error())
 		} else {
-			bufferedFiles[i] = file
+			repl.setRoot(root)
 		}
 	}
 
 	fmt.Printf("jqawk %s (revision %s)\n", version, getCommit())
 
+	var pendingSrc strings.Builder
+
 	for {
 		line, err := rl.Readline()
+		if err == readline.ErrInterrupt {
+			pendingSrc.Reset()
+			rl.SetPrompt(replPrompt)
+			continue
+		}
+		if err == io.EOF {
+			return 0
+		}
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "readline error: %s\n", err.Error())
 			return 1
 		}
-		line = strings.TrimSpace(line)
 
-		_, err = lang.EvalProgram(line, bufferedFiles, rootSelectors, os.Stdout, false)
+		if pendingSrc.Len() == 0 {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" {
+				continue
+			}
+			if strings.HasPrefix(trimmed, ":") {
+				if repl.runCommand(trimmed, rl) {
+					return 0
+				}
+				continue
+			}
+		}
+
+		pendingSrc.WriteString(line)
+		pendingSrc.WriteString("\n")
+		src := pendingSrc.String()
+
+		isProgram := strings.Contains(src, "{") || strings.Contains(src, "BEGIN")
+		cell, err := repl.ev.EvalLine(src, isProgram)
 		if err != nil {
-			printError(err)
+			if lang.IsUnexpectedEOF(err) {
+				rl.SetPrompt(replContinuationPrompt)
+				continue
+			}
+			printErrorAs(err, "text", "auto")
+			pendingSrc.Reset()
+			rl.SetPrompt(replPrompt)
+			continue
+		}
+
+		pendingSrc.Reset()
+		rl.SetPrompt(replPrompt)
+		if !isProgram && cell != nil {
+			fmt.Println(cell.Value.PrettyString(false))
 		}
-		fmt.Println("")
 	}
+}
+
+// replSession holds the REPL's state beyond the Evaluator itself: the array
+// (if any) that ":load" most recently bound $ to, and how far ":next" has
+// advanced through it.
+type replSession struct {
+	ev      *lang.Evaluator
+	current []*lang.Cell
+	index   int
+}
+
+func (r *replSession) setRoot(v lang.Value) {
+	r.ev.SetRoot(v)
+	if v.Tag == lang.ValueArray {
+		r.current = v.Array
+	} else {
+		r.current = nil
+	}
+	r.index = 0
+}
+
+// runCommand handles a ":"-prefixed line. It returns true when the REPL
+// should exit.
+func (r *replSession) runCommand(line string, rl *readline.Instance) bool {
+	switch {
+	case line == ":quit" || line == ":exit":
+		return true
+	case line == ":help":
+		printReplHelp()
+	case line == ":vi":
+		rl.SetVimMode(true)
+	case line == ":emacs":
+		rl.SetVimMode(false)
+	case line == ":reset":
+		ev, err := lang.NewReplEvaluator(os.Stdout)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return false
+		}
+		r.ev = ev
+		r.current = nil
+		r.index = 0
+	case line == ":functions":
+		for _, name := range r.ev.FunctionNames() {
+			fmt.Println(name)
+		}
+	case line == ":next":
+		if r.current == nil {
+			fmt.Fprintln(os.Stderr, "error: :next requires :load-ing an array first")
+			return false
+		}
+		if r.index >= len(r.current) {
+			fmt.Fprintln(os.Stderr, "error: no more elements")
+			return false
+		}
+		r.ev.SetRoot(r.current[r.index].Value)
+		r.index++
+		root := r.ev.Root()
+		fmt.Println(root.PrettyString(false))
+	case strings.HasPrefix(line, ":load "):
+		path := strings.TrimSpace(line[len(":load "):])
+		fp, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return false
+		}
+		defer fp.Close()
 
-	return 0
-}
+		root, err := decodeInputFile(lang.NewStreamingInputFile(path, fp), nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return false
+		}
+		r.setRoot(root)
+		rootVal := r.ev.Root()
+		fmt.Println(rootVal.PrettyString(false))
+	default:
+		fmt.Fprintf(os.Stderr, "error: unknown command %q (try :help)\n", line)
+	}
+	return false
+}
+
+func printReplHelp() {
+	fmt.Println(`commands:
+  :load <file>   decode <file> and bind the result to $
+  :next          advance $ to the next element of the array loaded with :load
+  :reset         start over with a fresh $ and no functions or variables
+  :functions     list user-defined functions
+  :vi, :emacs    switch the line editor's key bindings
+  :help          show this message
+  :quit, :exit   exit the REPL
+anything else is evaluated as a jqawk expression against $ (or as a full
+program, if it contains "{" or "BEGIN"); an unfinished program switches the
+prompt to "... " until enough lines have been entered to complete it`)
+}
+
+// replCompleter completes a "."-prefixed member name against the current $:
+// its own keys if it's an object, plus whatever its prototype contributes
+// (array/obj/str/num methods like "map" or "upper"). Anything not preceded
+// by a "." is left alone, since matching the soup of keywords, variables and
+// functions valid everywhere else isn't worth the complexity.
+type replCompleter struct {
+	repl *replSession
+}
+
+func (c *replCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	word, ok := completionWord(line, pos)
+	if !ok {
+		return nil, 0
+	}
+
+	root := c.repl.ev.Root()
+	names := make([]string, 0)
+	if root.Tag == lang.ValueObj && root.Obj != nil {
+		names = append(names, root.ObjKeys...)
+	}
+	names = append(names, lang.PrototypeMembers(root.Tag)...)
+
+	candidates := make([][]rune, 0)
+	for _, name := range names {
+		if strings.HasPrefix(name, word) {
+			candidates = append(candidates, []rune(name[len(word):]))
+		}
+	}
+	return candidates, len(word)
+}
+
+// completionWord returns the identifier fragment immediately before pos and
+// true, if and only if it's preceded by a ".".
+func completionWord(line []rune, pos int) (string, bool) {
+	start := pos
+	for start > 0 && isIdentRune(line[start-1]) {
+		start--
+	}
+	if start == 0 || line[start-1] != '.' {
+		return "", false
+	}
+	return string(line[start:pos]), true
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// decodeInputFile decodes file's first value using the format inferred from
+// its name (or forced by -F), applying the first of rootSelectors (if any)
+// as in lang.EvalProgram - except the REPL binds a single $, so only one
+// selector is honored. ndjson files are collected into an array so ":next"
+// can step through them like any other loaded array.
+func decodeInputFile(file lang.InputFile, rootSelectors []string) (lang.Value, error) {
+	format := ""
+	if hint, ok := file.(lang.FormatHint); ok {
+		format = hint.Format()
+	}
+	if format == "" {
+		format = lang.InputFormatOverride
+	}
+	if format == "" {
+		format = lang.DetectFormat(file.Name())
+	}
+
+	dec, err := lang.NewDecoder(format, file.NewReader(), lang.DecoderOptions{CSVHasHeader: lang.CSVHasHeader, CSVDelimiter: lang.CSVDelimiter})
+	if err != nil {
+		return lang.Value{}, err
+	}
+
+	var root lang.Value
+	if format == "ndjson" {
+		rows := make([]*lang.Cell, 0)
+		for {
+			v, err := dec.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return lang.Value{}, err
+			}
+			rows = append(rows, lang.NewCell(v))
+		}
+		root = lang.NewValue(rows)
+	} else {
+		root, err = dec.Next()
+		if err != nil {
+			return lang.Value{}, err
+		}
+	}
+
+	if len(rootSelectors) == 0 {
+		return root, nil
+	}
+
+	cell, err := lang.EvalExpression(rootSelectors[0], root, io.Discard)
+	if err != nil {
+		return lang.Value{}, err
+	}
+	return cell.Value, nil
+}
+
+func replHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return home + string(os.PathSeparator) + ".jqawk_history"
+}
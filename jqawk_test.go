@@ -2,15 +2,18 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	lang "github.com/alligator/jqawk/src"
+	"github.com/alligator/jqawk/src/compiler"
 )
 
 type testCase struct {
@@ -177,6 +180,26 @@ false
 		json:     "[2, 3, 4]",
 		expected: "9\n24\n2\n12\n",
 	},
+	{
+		name: "compound modulo operator",
+		prog: `
+		BEGIN { mod = 10 }
+		{ mod %= $ }
+		END { print mod }`,
+		json:     "[3, 4]",
+		expected: "1\n",
+	},
+	{
+		name: "compound assignment mutates array and object fields in place",
+		prog: `
+		$.count >= 2 {
+			$.count *= 10;
+			$.items[0] += 1;
+			print $.count, $.items[0];
+		}`,
+		json:     `[{ "count": 1, "items": [1] }, { "count": 2, "items": [5] }]`,
+		expected: "20 6\n",
+	},
 	{
 		name: "unary operators",
 		prog: `BEGIN {
@@ -263,6 +286,27 @@ string rpad: sponge     sponge
  float lpad:    2.3 0002.3
 `,
 	},
+	{
+		name: "emit writes a compact JSON line per call, independent of print",
+		prog: `
+			{
+				emit({ name: $.name, doubled: $.age * 2 });
+			}
+		`,
+		json:     `[{ "name": "gate", "age": 1 }, { "name": "sponge", "age": 2 }]`,
+		expected: "{\"name\":\"gate\",\"doubled\":2}\n{\"name\":\"sponge\",\"doubled\":4}\n",
+	},
+	{
+		name: "printf conversions",
+		prog: `BEGIN {
+			printf("%d %i %+d %05d\n", 42, -7, 3, 9)
+			printf("%x %X %#x %o\n", 255, 255, 255, 8)
+			printf("%c%c%c%c\n", 104, 101, "yo", "!")
+			printf("%e %.2e %g\n", 1234.5, 1234.5, 0.0001)
+			print sprintf("%s=%d", "total", 12)
+		}`,
+		expected: "42 -7 +3 00009\nff FF 0xff 10\nhey!\n1.234500e+03 1.23e+03 0.0001\ntotal=12\n",
+	},
 	{
 		name: "equal, not equal",
 		prog: `
@@ -281,6 +325,48 @@ string rpad: sponge     sponge
 		json:     `[{ "name": "gate", "age": 1 }, { "name": "sponge", "age": 2.300 }]`,
 		expected: "eq gate\nneq sponge\n",
 	},
+	{
+		name: "regex match binds positional and named capture groups",
+		prog: `
+			$.name ~ /^(?P<first>\w+) (\w+)$/ { print $1, $2, $["first"] }
+		`,
+		json:     `[{ "name": "Jane Doe" }]`,
+		expected: "Jane Doe Jane\n",
+	},
+	{
+		name: "regex capture groups don't leak into the next record",
+		prog: `
+			$.name ~ /^(\w+) (\w+)$/ { print $1 }
+			$.name !~ /^(\w+) (\w+)$/ { print $1 }
+		`,
+		json:          `[{ "name": "a b" }, { "name": "c" }]`,
+		expected:      "a\n",
+		expectedError: "unknown variable $1",
+	},
+	{
+		name: "regex match binds the full match as $0",
+		prog: `
+			$.name ~ /\w+ \w+/ { print $0 }
+		`,
+		json:     `[{ "name": "Jane Doe" }]`,
+		expected: "Jane Doe\n",
+	},
+	{
+		name: "regex literal with an i flag matches case-insensitively",
+		prog: `
+			$.name ~ /jane/i { print $.name }
+		`,
+		json:     `[{ "name": "Jane Doe" }, { "name": "John Doe" }]`,
+		expected: "Jane Doe\n",
+	},
+	{
+		name: "regex literal with an unknown flag is an error",
+		prog: `
+			$.name ~ /jane/q { print $.name }
+		`,
+		json:          `[{ "name": "Jane Doe" }]`,
+		expectedError: `unknown regex flag "q"`,
+	},
 	{
 		name:     "order of operations",
 		prog:     "$.age + 1 > 2 { print $.name }",
@@ -311,6 +397,56 @@ string rpad: sponge     sponge
 		json:     "[]",
 		expected: "7\nhello\n5\n",
 	},
+	{
+		name: "ternary conditional expression",
+		prog: `
+			BEGIN {
+				print 1 < 2 ? "yes" : "no";
+				print 1 > 2 ? "yes" : "no";
+				x = 1;
+				print x == 1 ? "one" : x == 2 ? "two" : "other";
+				print x == 3 ? "one" : x == 2 ? "two" : "other";
+			}
+		`,
+		json:     "[]",
+		expected: "yes\nno\none\nother\n",
+	},
+	{
+		name: "boxed operator desugars to a two-argument function",
+		prog: `
+			BEGIN {
+				print \+(3, 4);
+				add = \+;
+				print add(1, 2);
+				print \<(1, 2), \<(2, 1);
+			}
+		`,
+		json:     "[]",
+		expected: "7\n3\ntrue false\n",
+	},
+	{
+		name:          "boxing an operator without a binary rule is an error",
+		prog:          `BEGIN { print \=; }`,
+		json:          "[]",
+		expectedError: `= can't be boxed into a function`,
+	},
+	{
+		name: "self tail-recursive function runs past the call depth limit",
+		prog: `
+			function sum(n, acc) {
+				if (n == 0) {
+					return acc;
+				}
+				return sum(n - 1, acc + n);
+			}
+
+			BEGIN {
+				print sum(100000, 0);
+			}
+		`,
+		json:     "[]",
+		expected: "5000050000\n",
+	},
 	{
 		name: "if",
 		prog: `
@@ -455,6 +591,96 @@ string rpad: sponge     sponge
 		json:     "[[1, [2, 3]], [4, [5, 6]]]",
 		expected: "3\n4\n",
 	},
+	{
+		name: "match object pattern",
+		prog: `
+			{
+				print match ($) {
+					{ name: n, age: a } => n + ' ' + a,
+				}
+			}
+		`,
+		json:     `[{ "name": "gate", "age": 1 }]`,
+		expected: "gate 1\n",
+	},
+	{
+		name: "match array rest binding",
+		prog: `
+			{
+				print match ($) {
+					[x, y, ...tail] => tail,
+				}
+			}
+		`,
+		json:     "[[1, 2, 3, 4]]",
+		expected: "[3, 4]\n",
+	},
+	{
+		name: "match object rest binding",
+		prog: `
+			{
+				print match ($) {
+					{ a, ...rest } => rest,
+				}
+			}
+		`,
+		json:     `[{ "a": 1, "b": 2, "c": 3 }]`,
+		expected: `{"b": 2, "c": 3}` + "\n",
+	},
+	{
+		name: "match nested object pattern with an array rest field",
+		prog: `
+			{
+				match ($) {
+					{ kind: "point", coords: [x, ...rest] } => { print x, rest },
+					_ => { print 'no match' },
+				}
+			}
+		`,
+		json:     `[{ "kind": "point", "coords": [1, 2, 3] }, { "kind": "other" }]`,
+		expected: "1 [2, 3]\nno match\n",
+	},
+	{
+		name: "match type patterns and guard",
+		prog: `
+			{
+				print match ($) {
+					x is number if x < 0 => 'negative',
+					x is number => 'number',
+					x is string => 'string',
+					_ => 'other',
+				}
+			}
+		`,
+		json:     `[-5, 5, "hi", true]`,
+		expected: "negative\nnumber\nstring\nother\n",
+	},
+	{
+		name: "match alternation",
+		prog: `
+			{
+				print match ($) {
+					1 | 2 | 3 => 'small',
+					_ => 'big',
+				}
+			}
+		`,
+		json:     "[1, 2, 3, 4]",
+		expected: "small\nsmall\nsmall\nbig\n",
+	},
+	{
+		name: "match guard",
+		prog: `
+			{
+				print match ($) {
+					[x, y] if x < y => x,
+					[x, y] => y,
+				}
+			}
+		`,
+		json:     "[[1, 2], [5, 2]]",
+		expected: "1\n2\n",
+	},
 	{
 		name:     "length methods",
 		prog:     "{ print $.obj.length(), $.array.length(); }",
@@ -556,6 +782,111 @@ string rpad: sponge     sponge
 		json:     "[]",
 		expected: "0\n1\n3\n",
 	},
+	{
+		name: "labeled break exits the named outer loop from a nested one",
+		prog: `
+			BEGIN {
+				outer: for (i = 0; i < 3; i++) {
+					for (j = 0; j < 3; j++) {
+						if (i == 1 && j == 1) {
+							break outer;
+						}
+						print i, j;
+					}
+				}
+			}
+		`,
+		json:     "[]",
+		expected: "0 0\n0 1\n0 2\n1 0\n",
+	},
+	{
+		name: "labeled continue resumes the named outer loop from a nested one",
+		prog: `
+			BEGIN {
+				outer: for (i = 0; i < 3; i++) {
+					for (j = 0; j < 3; j++) {
+						if (j == 1) {
+							continue outer;
+						}
+						print i, j;
+					}
+				}
+			}
+		`,
+		json:     "[]",
+		expected: "0 0\n1 0\n2 0\n",
+	},
+	{
+		name: "do while runs its body at least once",
+		prog: `
+			BEGIN {
+				i = 0;
+				do {
+					print i;
+					i += 1;
+				} while (i < 3);
+			}
+		`,
+		json:     "[]",
+		expected: "0\n1\n2\n",
+	},
+	{
+		name: "do while runs exactly once when the condition is already false",
+		prog: `
+			BEGIN {
+				i = 5;
+				do {
+					print i;
+				} while (i < 3);
+			}
+		`,
+		json:     "[]",
+		expected: "5\n",
+	},
+	{
+		name: "labeled break exits a do while loop from a nested for",
+		prog: `
+			BEGIN {
+				i = 0;
+				outer: do {
+					for (j = 0; j < 3; j++) {
+						if (j == 1) {
+							break outer;
+						}
+						print i, j;
+					}
+					i += 1;
+				} while (i < 3);
+			}
+		`,
+		json:     "[]",
+		expected: "0 0\n",
+	},
+	{
+		name:          "break with an unresolved label is a parse-time error",
+		prog:          "BEGIN { for (i = 0; i < 1; i++) { break missing; } }",
+		json:          "[]",
+		expectedError: `unknown label "missing"`,
+	},
+	{
+		name:          "continue with an unresolved label is a parse-time error",
+		prog:          "BEGIN { for (i = 0; i < 1; i++) { continue missing; } }",
+		json:          "[]",
+		expectedError: `unknown label "missing"`,
+	},
+	{
+		name: "a label goes out of scope once its loop ends",
+		prog: `
+			BEGIN {
+				outer: for (i = 0; i < 1; i++) { }
+				for (j = 0; j < 1; j++) {
+					break outer;
+				}
+			}
+		`,
+		json:          "[]",
+		expectedError: `unknown label "outer"`,
+	},
 	{
 		name:     "next",
 		prog:     "{ print $; next } { print $ }",
@@ -574,6 +905,50 @@ string rpad: sponge     sponge
 		json:          "[]",
 		expectedError: "error creating JSON: circular reference",
 	},
+	{
+		name:     "json compact preset",
+		prog:     `BEGIN { o = {}; o.b = 2; o.a = 1; print json(o, "compact") }`,
+		json:     "[]",
+		expected: "{\"b\":2,\"a\":1}\n",
+	},
+	{
+		name:     "json sortKeys option",
+		prog:     `BEGIN { o = {}; o.b = 2; o.a = 1; print json(o, {compact: true, sortKeys: true}) }`,
+		json:     "[]",
+		expected: "{\"a\":1,\"b\":2}\n",
+	},
+	{
+		name:     "json ndjson preset",
+		prog:     `BEGIN { print json([1, 2, 3], "ndjson") }`,
+		json:     "[]",
+		expected: "1\n2\n3\n",
+	},
+	{
+		name: "jq filter field access and iteration",
+		prog: `
+			BEGIN {
+				people = [{name: "a", hours: 1}, {name: "b", hours: 0}]
+				print jq(".[] | select(.hours>0) | .name")(people)
+			}
+		`,
+		json:     "[]",
+		expected: "[\"a\"]\n",
+	},
+	{
+		name: "jq filter map and length",
+		prog: `
+			BEGIN {
+				# jq(...) always returns the array of every value the filter
+				# produced, so a filter that itself yields one array (map) or
+				# one scalar (length, identity) still comes back wrapped
+				print jq("map(.n * 2)")([{n: 1}, {n: 2}, {n: 3}])
+				print jq("length")([1, 2, 3])
+				print jq(".")(5)
+			}
+		`,
+		json:     "[]",
+		expected: "[[2, 4, 6]]\n[3]\n[5]\n",
+	},
 	{
 		name: "string methods",
 		prog: `
@@ -695,58 +1070,182 @@ rhs not null
 		expected: "[1, 2, 3, 4, 5]\n[{\"a\": 1}, [1], \"bee\", \"clown\", \"dog\"]\n[4, 5, 3, 1, 2]\n",
 	},
 	{
-		name: "beginfile endfile",
+		name: "array sort with comparator",
 		prog: `
-			BEGIN { print 'begin', $ }
-			BEGINFILE { print 'beginfile', $ }
-			ENDFILE { print 'endfile', $ }
-			END { print 'end', $ }
+			BEGIN {
+				a = [4, 5, 3, 1, 2];
+				print a.sort(function (x, y) { return y - x });
+			}
 		`,
-		json:     "123",
-		json2:    "456",
-		expected: "begin null\nbeginfile 123\nendfile 123\nbeginfile 456\nendfile 456\nend null\n",
+		json:     "[]",
+		expected: "[5, 4, 3, 2, 1]\n",
 	},
 	{
-		name: "$ is the root value in endfile",
+		name: "array map filter reduce find some every",
 		prog: `
-			BEGINFILE { $ = $.stuff }
-			{ print $ }
-			ENDFILE { print $ }
+			BEGIN {
+				a = [1, 2, 3, 4, 5];
+				print a.map(function (x) { return x * 2 });
+				print a.filter(function (x) { return x % 2 == 0 });
+				print a.reduce(function (acc, x) { return acc + x });
+				print a.reduce(function (acc, x) { return acc + x }, 10);
+				print a.find(function (x) { return x > 3 });
+				print a.find(function (x) { return x > 10 });
+				print a.some(function (x) { return x > 4 });
+				print a.every(function (x) { return x > 0 });
+				print a.every(function (x) { return x > 1 });
+				print a.map(function (x, i) { return i });
+			}
 		`,
-		json:     `{ "stuff": [1, 2, 3] }`,
-		expected: "1\n2\n3\n{\"stuff\": [1, 2, 3]}\n",
+		json:     "[]",
+		expected: "[2, 4, 6, 8, 10]\n[2, 4]\n15\n25\n4\nnull\ntrue\ntrue\nfalse\n[0, 1, 2, 3, 4]\n",
 	},
 	{
-		name: "num methods",
+		name: "reduce of an empty array with no initial value is an error",
 		prog: `
 			BEGIN {
-				a = 2.5
-				print a.floor()
-				print a.ceil()
-				print a.round()
-				print (3.5).round()
+				a = [];
+				print a.reduce(function (acc, x) { return acc + x });
 			}
 		`,
-		json:     "[]",
-		expected: "2\n3\n3\n4\n",
-	},
-	{
-		name:     "jsonl",
-		prog:     "{ print $ }",
-		json:     "[1, 2]\n[3, 4]",
-		expected: "1\n2\n3\n4\n",
+		json:          "[]",
+		expectedError: "reduce of an empty array with no initial value",
 	},
 	{
-		name:     "escape chars",
-		prog:     `BEGIN { print 'one\ntwo\tthree\\four' }`,
+		name: "indexing far beyond an array's length auto-fills it up to the index",
+		prog: `
+			BEGIN {
+				a = [1, 2, 3];
+				a[10] = "x";
+				print a.length();
+				print a[5];
+				print a[10];
+			}
+		`,
 		json:     "[]",
-		expected: "one\ntwo\tthree\\four\n",
+		expected: "11\nnull\nx\n",
 	},
 	{
-		name:          "invalid escape chars",
-		prog:          `BEGIN { print '\z' }`,
-		json:          "[]",
-		expectedError: "unknown escape char 'z'",
+		name: "indexing an array far enough out of range is an error instead of allocating forever",
+		prog: `
+			BEGIN {
+				a = [1, 2, 3];
+				a[2000000000] = "x";
+			}
+		`,
+		json:          "[]",
+		expectedError: "index too large to auto-fill array (can't grow by more than 1048576 elements at once)",
+	},
+	{
+		name: "string prototype methods",
+		prog: `
+			BEGIN {
+				print "  hi  ".trim();
+				print "  hi  ".trimStart();
+				print "  hi  ".trimEnd();
+				print "hello world".replace("o", "0");
+				print "hello world".replaceAll("o", "0");
+				print "foo.bar".replace(/\./, "-");
+				print "hello".startsWith("he");
+				print "hello".endsWith("lo");
+				print "hello".contains("ell");
+				print "hello".indexOf("l");
+				print "ab".repeat(3);
+				print "7".padStart(3, "0");
+				print "7".padEnd(3, "0");
+				print "hello".slice(1, 3);
+				print "hello".slice(-3);
+				print "abc".chars();
+			}
+		`,
+		json:     "[]",
+		expected: "hi\nhi  \n  hi\nhell0 world\nhell0 w0rld\nfoo-bar\ntrue\ntrue\ntrue\n2\nababab\n007\n700\nel\nllo\n[\"a\", \"b\", \"c\"]\n",
+	},
+	{
+		name: "number prototype methods",
+		prog: `
+			BEGIN {
+				print (-3.25).abs();
+				print (-3).sign();
+				print (3).sign();
+				print (0).sign();
+				print (10).pow(400).isFinite();
+				print (1).isFinite();
+				print (2).pow(10);
+				print (3.14159).toFixed(2);
+				print (255).toString(16);
+			}
+		`,
+		json:     "[]",
+		expected: "3.25\n-1\n1\n0\nfalse\ntrue\n1024\n3.14\nff\n",
+	},
+	{
+		name: "object prototype methods",
+		prog: `
+			BEGIN {
+				a = {x: 1, y: 2};
+				print a.keys();
+				print a.values();
+				print a.entries();
+				print a.merge({y: 3, z: 4});
+			}
+		`,
+		json:     "[]",
+		expected: "[\"x\", \"y\"]\n[1, 2]\n[[\"x\", 1], [\"y\", 2]]\n{\"x\": 1, \"y\": 3, \"z\": 4}\n",
+	},
+	{
+		name: "beginfile endfile",
+		prog: `
+			BEGIN { print 'begin', $ }
+			BEGINFILE { print 'beginfile', $ }
+			ENDFILE { print 'endfile', $ }
+			END { print 'end', $ }
+		`,
+		json:     "123",
+		json2:    "456",
+		expected: "begin null\nbeginfile 123\nendfile 123\nbeginfile 456\nendfile 456\nend null\n",
+	},
+	{
+		name: "$ is the root value in endfile",
+		prog: `
+			BEGINFILE { $ = $.stuff }
+			{ print $ }
+			ENDFILE { print $ }
+		`,
+		json:     `{ "stuff": [1, 2, 3] }`,
+		expected: "1\n2\n3\n{\"stuff\": [1, 2, 3]}\n",
+	},
+	{
+		name: "num methods",
+		prog: `
+			BEGIN {
+				a = 2.5
+				print a.floor()
+				print a.ceil()
+				print a.round()
+				print (3.5).round()
+			}
+		`,
+		json:     "[]",
+		expected: "2\n3\n3\n4\n",
+	},
+	{
+		name:     "jsonl",
+		prog:     "{ print $ }",
+		json:     "[1, 2]\n[3, 4]",
+		expected: "1\n2\n3\n4\n",
+	},
+	{
+		name:     "escape chars",
+		prog:     `BEGIN { print 'one\ntwo\tthree\\four' }`,
+		json:     "[]",
+		expected: "one\ntwo\tthree\\four\n",
+	},
+	{
+		name:          "invalid escape chars",
+		prog:          `BEGIN { print '\z' }`,
+		json:          "[]",
+		expectedError: "unknown escape char 'z'",
 	},
 	{
 		name: "bug: statement after block",
@@ -773,6 +1272,18 @@ rhs not null
 		json:          "[1]",
 		expectedError: "unexpected EOF while reading string",
 	},
+	{
+		name:          "parse error lists every expected token",
+		prog:          `{ if (true print "hi" }`,
+		json:          "[1]",
+		expectedError: "expected one of { ) } but found Print",
+	},
+	{
+		name:          "parse error for is expression lists its expected token set",
+		prog:          `{ print 1 is 5 }`,
+		json:          "[1]",
+		expectedError: "expected one of { Ident, Function, Null } but found Num",
+	},
 	{
 		name: "bug: nested return",
 		prog: `
@@ -873,6 +1384,12 @@ rhs not null
 		json:          "[1]",
 		expectedError: "can only continue inside a loop",
 	},
+	{
+		name:          "bug: a label on a non-loop statement",
+		prog:          "BEGIN { outer: print 1 }",
+		json:          "[]",
+		expectedError: "labels can only be used on for/while/do loops",
+	},
 	{
 		name: "bug: pushing arrays to arrays",
 		prog: `
@@ -889,6 +1406,48 @@ rhs not null
 		json:     "[1, 2, 3]",
 		expected: "[[1], [2], [3]]\n",
 	},
+	{
+		name: "import builtin math module",
+		prog: `
+			import math "math"
+			BEGIN { print math.max(3, 7), math.abs(-2), math.pow(2, 10) }
+		`,
+		json:     "[]",
+		expected: "7 2 1024\n",
+	},
+	{
+		name: "import builtin module with default alias",
+		prog: `
+			import "strings"
+			BEGIN { print strings.trim("  hi  "), strings.startsWith("hello", "he") }
+		`,
+		json:     "[]",
+		expected: "hi true\n",
+	},
+	{
+		name: "yaml builtin",
+		prog: `
+			BEGIN { print yaml({name: "alice", age: 30}) }
+		`,
+		json:     "[]",
+		expected: "name: alice\nage: 30\n\n",
+	},
+	{
+		name: "toml builtin",
+		prog: `
+			BEGIN { print toml({name: "alice", age: 30}) }
+		`,
+		json:     "[]",
+		expected: "name = \"alice\"\nage = 30\n\n",
+	},
+	{
+		name: "csv builtin",
+		prog: `
+			BEGIN { print csv([["name", "age"], ["alice", 30]]) }
+		`,
+		json:     "[]",
+		expected: "name,age\nalice,30\n\n",
+	},
 }
 
 func TestMain(m *testing.M) {
@@ -912,7 +1471,7 @@ func FuzzJqawk(f *testing.F) {
 		input := "[{ \"a\": 1 }, { \"a\": null }]"
 		inputReader := strings.NewReader(input)
 		inputFiles := []lang.InputFile{
-			{Name: "<test>", Reader: inputReader},
+			lang.NewStreamingInputFile("<test>", inputReader),
 		}
 		_, err := lang.EvalProgram(src, inputFiles, nil, io.Discard, true)
 
@@ -937,7 +1496,7 @@ func FuzzJqawkWithJson(f *testing.F) {
 	f.Fuzz(func(t *testing.T, src string, jsonSrc string) {
 		inputReader := strings.NewReader(jsonSrc)
 		inputFiles := []lang.InputFile{
-			{Name: "<test>", Reader: inputReader},
+			lang.NewStreamingInputFile("<test>", inputReader),
 		}
 		_, err := lang.EvalProgram(src, inputFiles, nil, io.Discard, true)
 
@@ -979,11 +1538,11 @@ func test(t *testing.T, tc testCase) {
 		inputFiles := make([]lang.InputFile, 0)
 		if tc.json != "" {
 			inputReader := strings.NewReader(tc.json)
-			inputFiles = append(inputFiles, lang.InputFile{Name: "<test1>", Reader: inputReader})
+			inputFiles = append(inputFiles, lang.NewStreamingInputFile("<test1>", inputReader))
 		}
 		if tc.json2 != "" {
 			inputReader := strings.NewReader(tc.json2)
-			inputFiles = append(inputFiles, lang.InputFile{Name: "<test2>", Reader: inputReader})
+			inputFiles = append(inputFiles, lang.NewStreamingInputFile("<test2>", inputReader))
 		}
 
 		var sb strings.Builder
@@ -1056,6 +1615,69 @@ func TestJqawkExe(t *testing.T) {
 		expected: "2\n3\n0\n1\n",
 	})
 
+	testExe(t, testCase{
+		name:     "-P JSONPath root selector with a wildcard",
+		args:     []string{"-P", "$.store.book[*]", "{ print $.title }"},
+		json:     `{ "store": { "book": [ { "title": "A", "price": 5 }, { "title": "B", "price": 15 } ] } }`,
+		expected: "A\nB\n",
+	})
+
+	testExe(t, testCase{
+		name:     "-P JSONPath filter predicate binds @ to the candidate node",
+		args:     []string{"-P", "$.store.book[?(@.price<10)]", "{ print $.title }"},
+		json:     `{ "store": { "book": [ { "title": "A", "price": 5 }, { "title": "B", "price": 15 } ] } }`,
+		expected: "A\n",
+	})
+
+	testExe(t, testCase{
+		name:     "-P JSONPath deep scan",
+		args:     []string{"-P", "$..author", "{ print }"},
+		json:     `{ "store": { "book": [ { "author": "X" }, { "author": "Y" } ], "bicycle": { "author": "Z" } } }`,
+		expected: "X\nY\nZ\n",
+	})
+
+	testExe(t, testCase{
+		name:     "-P JSONPath slice",
+		args:     []string{"-P", "$.items[0:3]", "{ print }"},
+		json:     `{ "items": [0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10] }`,
+		expected: "0\n1\n2\n",
+	})
+
+	testExe(t, testCase{
+		name:     "jsonpath() builtin queries a value from within a program",
+		args:     []string{`{ print jsonpath($, "$.store.book[?(@.price<10)].title") }`},
+		json:     `{ "store": { "book": [ { "title": "A", "price": 5 }, { "title": "B", "price": 15 } ] } }`,
+		expected: "[\"A\"]\n",
+	})
+
+	testExe(t, testCase{
+		name:     "-R walks every descendant in pre-order, not just root's direct children",
+		args:     []string{"-R", "{ print $path, $ }"},
+		json:     `{ "a": { "b": 1, "c": [2, 3] } }`,
+		expected: "[\"a\"] {\"b\": 1, \"c\": [2, 3]}\n[\"a\", \"b\"] 1\n[\"a\", \"c\"] [2, 3]\n[\"a\", \"c\", 0] 2\n[\"a\", \"c\", 1] 3\n",
+	})
+
+	testExe(t, testCase{
+		name:     "-R sets $key to the visited node's immediate parent key",
+		args:     []string{"-R", "{ print $key }"},
+		json:     `{ "a": { "b": 1 } }`,
+		expected: "a\nb\n",
+	})
+
+	testExe(t, testCase{
+		name:     "-R sets $index to the visited node's immediate parent index",
+		args:     []string{"-R", "{ print $index }"},
+		json:     `[[1, 2]]`,
+		expected: "0\n0\n1\n",
+	})
+
+	testExe(t, testCase{
+		name:     "--jobs runs each record's pattern rules concurrently but keeps output in order",
+		args:     []string{"-F", "jsonseq", "--jobs", "4", "{ print $.a * 2 }"},
+		json:     "\x1e{\"a\":1}\n\x1e{\"a\":2}\n\x1e{\"a\":3}\n\x1e{\"a\":4}\n\x1e{\"a\":5}\n",
+		expected: "2\n4\n6\n8\n10\n",
+	})
+
 	testExe(t, testCase{
 		name: "json output",
 		args: []string{"-o", "-", "{ $.x++ }"},
@@ -1076,6 +1698,227 @@ func TestJqawkExe(t *testing.T) {
 		json:     "[]",
 		expected: "",
 	})
+
+	testExe(t, testCase{
+		name:     "-bignum preserves integers larger than a float64 can hold exactly",
+		args:     []string{"-bignum", "{ print $.id, $.id > 500 }"},
+		json:     `[{ "id": 4722366482869645213697 }]`,
+		expected: "4722366482869645213697 true\n",
+	})
+
+	testExe(t, testCase{
+		name:     "without -bignum large integers round to the nearest float64",
+		args:     []string{"{ print $.id }"},
+		json:     `[{ "id": 4722366482869645213697 }]`,
+		expected: "4722366482869645000000\n",
+	})
+
+	testExe(t, testCase{
+		name:     "-F jsonseq decodes an RFC 7464 JSON text sequence, one record per value",
+		args:     []string{"-F", "jsonseq", "{ print $.a }"},
+		json:     "\x1e{\"a\":1}\n\x1e{\"a\":2}\n",
+		expected: "1\n2\n",
+	})
+
+	testExe(t, testCase{
+		name:     "a leading 0x1E byte auto-detects jsonseq input without -F",
+		args:     []string{"{ print $.a }"},
+		json:     "\x1e{\"a\":1}\n\x1e{\"a\":2}\n",
+		expected: "1\n2\n",
+	})
+
+	testExe(t, testCase{
+		name:     "-print-format table aligns columns, right-aligning numbers",
+		args:     []string{"-print-format", "table", "{ print $.name, $.age }"},
+		json:     `[{ "name": "Alice", "age": 30 }, { "name": "Bob", "age": 5 }]`,
+		expected: "Alice  30\nBob     5\n",
+	})
+
+	testExe(t, testCase{
+		name:     "-print-format table derives a header from a whole-record print",
+		args:     []string{"-print-format", "table", "{ print }"},
+		json:     `[{ "name": "Alice", "age": 30 }, { "name": "Bob", "age": 5 }]`,
+		expected: "name   age\nAlice   30\nBob      5\n",
+	})
+
+	testExe(t, testCase{
+		name:     "-F csv with --csv-header",
+		args:     []string{"-F", "csv", "--csv-header", "{ print $.name }"},
+		json:     "name,age\nalice,30\nbob,25\n",
+		expected: "alice\nbob\n",
+	})
+
+	testExe(t, testCase{
+		name:     "-F csv with --csv-header exposes fields positionally too",
+		args:     []string{"-F", "csv", "--csv-header", `{ print $[0] }`},
+		json:     "name,age\nalice,30\nbob,25\n",
+		expected: "alice\nbob\n",
+	})
+
+	testExe(t, testCase{
+		name:     "-F tsv",
+		args:     []string{"-F", "tsv", `$[1] > 26 { print $[0] }`},
+		json:     "alice\t30\nbob\t25\n",
+		expected: "alice\n",
+	})
+
+	testExe(t, testCase{
+		name:     "-F csv with --csv-delim",
+		args:     []string{"-F", "csv", "--csv-delim", "|", `{ print $[1] }`},
+		json:     "alice|30\nbob|25\n",
+		expected: "30\n25\n",
+	})
+}
+
+func TestJqawkInPlace(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "jqawk-in-place-*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`[{ "count": 1 }, { "count": 2 }]`); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	cmd := exec.Command("./jqawk", "-in-place", "{ $.count *= 10 }", file.Name())
+	var stdErr strings.Builder
+	cmd.Stderr = &stdErr
+	if err := cmd.Run(); err != nil {
+		t.Logf("stderr: %s\n", stdErr.String())
+		t.Fatal(err)
+	}
+
+	contents, err := os.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(contents, &decoded); err != nil {
+		t.Fatalf("output wasn't valid JSON: %s\n%s", err, contents)
+	}
+	if decoded[0]["count"] != float64(10) || decoded[1]["count"] != float64(20) {
+		t.Fatalf("unexpected contents: %s", contents)
+	}
+}
+
+func TestJqawkInclude(t *testing.T) {
+	dir := t.TempDir()
+
+	lib := `
+		BEGIN { print "lib begin" }
+		END { print "lib end" }
+		function greet(name) {
+			return "hi " + name
+		}
+	`
+	if err := os.WriteFile(filepath.Join(dir, "lib.jqawk"), []byte(lib), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	main := `
+		include "lib.jqawk"
+		BEGIN { print "main begin" }
+		END { print "main end" }
+		BEGIN { print greet("world") }
+	`
+	mainPath := filepath.Join(dir, "main.jqawk")
+	if err := os.WriteFile(mainPath, []byte(main), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testExe(t, testCase{
+		name:     "include merges BEGIN before, END after the including file's own",
+		args:     []string{"-f", mainPath},
+		json:     "[]",
+		expected: "lib begin\nmain begin\nhi world\nmain end\nlib end\n",
+	})
+}
+
+func TestJqawkMultipleProgFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	first := `BEGIN { print "first" }`
+	second := `BEGIN { print "second" }`
+	firstPath := filepath.Join(dir, "first.jqawk")
+	secondPath := filepath.Join(dir, "second.jqawk")
+	if err := os.WriteFile(firstPath, []byte(first), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(secondPath, []byte(second), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testExe(t, testCase{
+		name:     "repeated -f concatenates files in order without BEGIN/END reordering",
+		args:     []string{"-f", firstPath, "-f", secondPath},
+		json:     "[]",
+		expected: "first\nsecond\n",
+	})
+}
+
+func TestRuntimeErrorKeepsNestedPosition(t *testing.T) {
+	prog := `
+		function divide(a, b) {
+			return a / b
+		}
+
+		BEGIN {
+			divide(1, 0)
+		}
+	`
+
+	inputFiles := []lang.InputFile{
+		lang.NewStreamingInputFile("<test>", strings.NewReader("[]")),
+	}
+	_, err := lang.EvalProgram(prog, inputFiles, nil, io.Discard, false)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	runtimeErr, ok := err.(lang.RuntimeError)
+	if !ok {
+		t.Fatalf("expected a lang.RuntimeError, got %#v", err)
+	}
+	if runtimeErr.Line != 3 {
+		t.Fatalf("expected the error to point at divide's own `a / b` on line 3, got line %d", runtimeErr.Line)
+	}
+}
+
+func TestAstDumpAndPrint(t *testing.T) {
+	prog := `
+		BEGIN {
+			x = { a: 1, b: [1, 2] }
+			print x.a, x["b"]
+		}
+	`
+
+	lex := lang.NewLexer(prog)
+	parser := lang.NewParser(&lex)
+	program, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+
+	var dump strings.Builder
+	if err := lang.Fdump(&dump, lex.FileSet(), program); err != nil {
+		t.Fatalf("Fdump returned an error: %s", err)
+	}
+	for _, want := range []string{"Program", "ExprBinary", "ExprObject"} {
+		if !strings.Contains(dump.String(), want) {
+			t.Fatalf("expected dump to mention %q, got:\n%s", want, dump.String())
+		}
+	}
+
+	var printed strings.Builder
+	if err := lang.Fprint(&printed, lex.FileSet(), program); err != nil {
+		t.Fatalf("Fprint returned an error: %s", err)
+	}
+	for _, want := range []string{"BEGIN", "x.a", `x["b"]`} {
+		if !strings.Contains(printed.String(), want) {
+			t.Fatalf("expected printed source to mention %q, got:\n%s", want, printed.String())
+		}
+	}
 }
 
 func TestJqawkStreamingJson(t *testing.T) {
@@ -1135,6 +1978,77 @@ func TestJqawkStreamingJson(t *testing.T) {
 	}
 }
 
+func TestJqawkRepl(t *testing.T) {
+	// this is another special-case of the exe tests: it drives `jqawk -i` as
+	// an interactive session, writing lines to stdin and reading jqawk's
+	// printed results back off stdout (the "jqawk <version>" banner line is
+	// consumed and discarded first).
+	cmd := exec.Command("./jqawk", "-i")
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatalf("error opening stdin: %s\n", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("error opening stdout: %s\n", err)
+	}
+	br := bufio.NewReader(stdout)
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		t.Fatalf("error opening stderr: %s\n", err)
+	}
+
+	defer func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			t.Logf("stderr: %s\n", scanner.Text())
+		}
+	}()
+
+	err = cmd.Start()
+	if err != nil {
+		t.Fatalf("error starting command: %s\n", err)
+	}
+
+	if _, err := br.ReadString('\n'); err != nil {
+		t.Fatalf("error reading banner: %s\n", err)
+	}
+
+	writeStdinAndExpectOutput := func(input string, expected string) {
+		io.WriteString(stdin, input+"\n")
+		str, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			t.Fatalf("error reading stdout: %s\n", err)
+		}
+		if str != expected {
+			t.Fatalf("expected %q\ngot %q\n", expected, str)
+		}
+	}
+
+	// a function declaration produces no output, so it's just written, not
+	// read back; the following line calling it is the first real output.
+	io.WriteString(stdin, "function double(x) { return x * 2 }\n")
+	writeStdinAndExpectOutput("double(21)", "42\n")
+
+	// a function declaration split across lines shouldn't be evaluated (and
+	// fail) until its closing "}" arrives - the REPL should keep prompting
+	// for more input instead.
+	io.WriteString(stdin, "function triple(x) {\n")
+	io.WriteString(stdin, "\treturn x * 3\n")
+	io.WriteString(stdin, "}\n")
+	writeStdinAndExpectOutput("triple(7)", "21\n")
+
+	stdin.Close()
+
+	err = cmd.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestJqawkOneTrueAwk(t *testing.T) {
 	countries := `[
 		["Russia", 8650, 262, "Asia"],
@@ -1236,3 +2150,332 @@ func TestJqawkOneTrueAwk(t *testing.T) {
 		expected: "Russia\nChina\nIndia\n",
 	})
 }
+
+func TestStreamDecoder(t *testing.T) {
+	sd, err := lang.NewStreamDecoder(strings.NewReader(`[1, 2, 3]`), "$[*]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for {
+		v, err := sd.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v.String())
+	}
+
+	want := []string{"1", "2", "3"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+}
+
+func TestStreamDecoderNested(t *testing.T) {
+	src := `{ "meta": "ignored", "items": [{ "id": 1 }, { "id": 2 }] }`
+	sd, err := lang.NewStreamDecoder(strings.NewReader(src), "$.items[*]")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for {
+		_, err := sd.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		count++
+	}
+
+	if count != 2 {
+		t.Fatalf("expected 2 items, got %d", count)
+	}
+}
+
+func TestPrintErrorJSON(t *testing.T) {
+	err := lang.SyntaxError{
+		Message: "unexpected token }",
+		Line:    3,
+		Col:     5,
+		SrcLine: "  } }",
+		EndLine: 3,
+		EndCol:  6,
+	}
+
+	var sb strings.Builder
+	if jsonErr := lang.PrintErrorJSON(&sb, err); jsonErr != nil {
+		t.Fatal(jsonErr)
+	}
+
+	var decoded map[string]interface{}
+	if jsonErr := json.Unmarshal([]byte(sb.String()), &decoded); jsonErr != nil {
+		t.Fatalf("output wasn't valid JSON: %s\n%s", jsonErr, sb.String())
+	}
+
+	if decoded["kind"] != "syntax" {
+		t.Fatalf("expected kind %q, got %q", "syntax", decoded["kind"])
+	}
+	if decoded["message"] != "unexpected token }" {
+		t.Fatalf("unexpected message %q", decoded["message"])
+	}
+	if decoded["line"] != float64(3) || decoded["col"] != float64(5) {
+		t.Fatalf("unexpected position %v:%v", decoded["line"], decoded["col"])
+	}
+}
+
+func TestStreamDecoderBadSelector(t *testing.T) {
+	if _, err := lang.NewStreamDecoder(strings.NewReader(`[]`), "items[*]"); err == nil {
+		t.Fatal("expected an error for a selector missing the leading $")
+	}
+	if _, err := lang.NewStreamDecoder(strings.NewReader(`[]`), "$.items"); err == nil {
+		t.Fatal("expected an error for a selector missing the trailing [*]")
+	}
+}
+
+// TestInputFileFormatHint checks that an InputFile implementing FormatHint
+// (see src/input_file.go) decodes as that format regardless of what its
+// name's extension would otherwise auto-detect, so a caller juggling
+// several input files in different formats in the same run isn't limited
+// to the one global -F override.
+func TestInputFileFormatHint(t *testing.T) {
+	yamlContent := []byte("a: 1\nb: 2\n")
+	file := lang.NewBufferedInputFileWithFormat("data.json", yamlContent, "yaml")
+
+	var sb strings.Builder
+	if _, err := lang.EvalProgram("{ print $.a, $.b }", []lang.InputFile{file}, nil, &sb, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if sb.String() != "1 2\n" {
+		t.Fatalf("expected %q, got %q", "1 2\n", sb.String())
+	}
+}
+
+// TestHostFuncs checks that lang.HostFuncs (see src/hostfuncs.go) exposes
+// plain Go functions as script-callable globals, converting arguments and
+// return values through reflection, and that a non-nil trailing error
+// becomes a script-level runtime error.
+func TestHostFuncs(t *testing.T) {
+	lang.HostFuncs = map[string]interface{}{
+		"double": func(x float64) float64 { return x * 2 },
+		"upper": func(s string) (string, error) {
+			if s == "" {
+				return "", errors.New("upper: empty string")
+			}
+			return strings.ToUpper(s), nil
+		},
+	}
+	defer func() { lang.HostFuncs = nil }()
+
+	var sb strings.Builder
+	_, err := lang.EvalProgram(`{ print double(21), upper("ok") }`, []lang.InputFile{lang.NewBufferedInputFile("data.json", []byte("{}"))}, nil, &sb, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != "42 OK\n" {
+		t.Fatalf("expected %q, got %q", "42 OK\n", sb.String())
+	}
+
+	sb.Reset()
+	_, err = lang.EvalProgram(`{ print upper("") }`, []lang.InputFile{lang.NewBufferedInputFile("data.json", []byte("{}"))}, nil, &sb, false)
+	if err == nil {
+		t.Fatal("expected an error from upper(\"\")")
+	}
+}
+
+// TestParserReportsMultipleErrors checks that a program with several
+// unrelated syntax errors gets all of them reported from one Parse call
+// (via findNextStatement's recovery), sorted in source order (see
+// ErrorList.Sort), instead of stopping at the first.
+func TestParserReportsMultipleErrors(t *testing.T) {
+	prog := `
+		BEGIN {
+			1 +;
+			print "ok";
+		}
+		BEGIN {
+			2 +;
+		}
+	`
+	lex := lang.NewLexer(prog)
+	parser := lang.NewParser(&lex)
+	_, err := parser.Parse()
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+
+	group, ok := err.(lang.ErrorGroup)
+	if !ok {
+		t.Fatalf("expected an ErrorGroup, got %T: %s", err, err)
+	}
+	if len(group.Errors) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %s", len(group.Errors), err)
+	}
+
+	first, ok := group.Errors[0].(lang.SyntaxError)
+	if !ok {
+		t.Fatalf("expected a SyntaxError, got %T", group.Errors[0])
+	}
+	second, ok := group.Errors[1].(lang.SyntaxError)
+	if !ok {
+		t.Fatalf("expected a SyntaxError, got %T", group.Errors[1])
+	}
+	if first.Line >= second.Line {
+		t.Fatalf("expected errors sorted by line, got lines %d then %d", first.Line, second.Line)
+	}
+}
+
+func TestParserUnexpectedEOF(t *testing.T) {
+	// an unterminated BEGIN block is what the REPL's multi-line continuation
+	// watches for (lang.IsUnexpectedEOF) to decide whether to prompt for
+	// another line instead of reporting a failure.
+	lex := lang.NewLexer("BEGIN {\n\tprint 1\n")
+	parser := lang.NewParser(&lex)
+	_, err := parser.Parse()
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+	if !lang.IsUnexpectedEOF(err) {
+		t.Fatalf("expected an unexpected-EOF error, got %T: %s", err, err)
+	}
+}
+
+func TestParserGenuineErrorIsNotUnexpectedEOF(t *testing.T) {
+	// "break" outside a loop is a real mistake that happens to sit at the
+	// end of the program; IsUnexpectedEOF must not mistake it for one more
+	// line of input fixing things.
+	lex := lang.NewLexer("BEGIN {\n\tbreak\n}")
+	parser := lang.NewParser(&lex)
+	_, err := parser.Parse()
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+	if lang.IsUnexpectedEOF(err) {
+		t.Fatalf("expected a genuine error, got an unexpected-EOF error: %s", err)
+	}
+}
+
+func TestLoadSourcesMergesInOrderWithoutReordering(t *testing.T) {
+	loader := lang.NewProgramLoader(nil)
+	prog, _, err := loader.LoadSources([]lang.NamedSource{
+		{Name: "first.jqawk", Src: `BEGIN { print "first" }`, Dir: "."},
+		{Name: "second.jqawk", Src: `BEGIN { print "second" }`, Dir: "."},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(prog.Rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(prog.Rules))
+	}
+}
+
+func TestLoadSourcesErrorNamesTheOriginatingSource(t *testing.T) {
+	loader := lang.NewProgramLoader(nil)
+	_, _, err := loader.LoadSources([]lang.NamedSource{
+		{Name: "first.jqawk", Src: `BEGIN { print "ok" }`, Dir: "."},
+		{Name: "second.jqawk", Src: `BEGIN { 1 +; }`, Dir: "."},
+	})
+	if err == nil {
+		t.Fatal("expected a parse error")
+	}
+
+	var sb strings.Builder
+	lang.PrintErrorTo(&sb, err, lang.ErrorPrintOptions{})
+	if !strings.Contains(sb.String(), "second.jqawk") {
+		t.Fatalf("expected the error to name second.jqawk, got %q", sb.String())
+	}
+}
+
+func compileProgram(t *testing.T, prog string) string {
+	t.Helper()
+	lex := lang.NewLexer(prog)
+	parser := lang.NewParser(&lex)
+	ast, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	src, err := compiler.Compile(&ast, &lex, "main")
+	if err != nil {
+		t.Fatalf("unexpected compile error: %s", err)
+	}
+	return src
+}
+
+// TestCompilerGolden checks the Go source the compiler emits for a handful
+// of the features already covered by the interpreter tests above. The repo
+// snapshot has no go.mod/main.go of its own, so these can't go the last
+// step and `go build` + run the emitted source; they assert on the
+// generated call shape instead.
+func TestCompilerGolden(t *testing.T) {
+	tests := []struct {
+		name     string
+		prog     string
+		contains []string
+	}{
+		{
+			name:     "print literal and arithmetic",
+			prog:     `BEGIN { print "sum:", 1 + 2 }`,
+			contains: []string{"func begin0() {", `NewStr("sum:")`, "NewNum(1).Add(NewNum(2))", "fmt.Println("},
+		},
+		{
+			name:     "string concat",
+			prog:     `BEGIN { print "a" + "b" }`,
+			contains: []string{`NewStr("a").Add(NewStr("b"))`},
+		},
+		{
+			name:     "comparison and logical",
+			prog:     `BEGIN { print 1 < 2 && true }`,
+			contains: []string{"NewNum(1).Lt(NewNum(2)).And(NewBool(true))"},
+		},
+		{
+			name:     "array and object literals",
+			prog:     `BEGIN { print [1, 2], {a: "x"} }`,
+			contains: []string{"NewArray(NewNum(1), NewNum(2))", `NewObject(Entry("a", NewStr("x")))`},
+		},
+		{
+			name:     "printf call",
+			prog:     `BEGIN { printf("%s=%d\n", "n", 1) }`,
+			contains: []string{`Printf(NewStr("%s=%d\n"), NewStr("n"), NewNum(1))`},
+		},
+		{
+			name:     "if/else",
+			prog:     `BEGIN { if (1 < 2) { print "y" } else { print "n" } }`,
+			contains: []string{"if NewNum(1).Lt(NewNum(2)).Truthy() {", "} else {"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			src := compileProgram(t, tc.prog)
+			for _, want := range tc.contains {
+				if !strings.Contains(src, want) {
+					t.Fatalf("expected generated source to contain %q\ngot:\n%s", want, src)
+				}
+			}
+		})
+	}
+}
+
+func TestCompilerRejectsPatternRules(t *testing.T) {
+	lex := lang.NewLexer(`{ print }`)
+	parser := lang.NewParser(&lex)
+	ast, err := parser.Parse()
+	if err != nil {
+		t.Fatalf("unexpected parse error: %s", err)
+	}
+	if _, err := compiler.Compile(&ast, &lex, "main"); err == nil {
+		t.Fatal("expected pattern rules to be reported as unsupported")
+	}
+}
@@ -19,6 +19,8 @@ type RuleKind uint8
 const (
 	BeginRule RuleKind = iota
 	EndRule
+	BeginFileRule
+	EndFileRule
 	PatternRule
 )
 
@@ -28,6 +30,10 @@ func (k RuleKind) String() string {
 		return "BeginRule"
 	case EndRule:
 		return "EndRule"
+	case BeginFileRule:
+		return "BeginFileRule"
+	case EndFileRule:
+		return "EndFileRule"
 	case PatternRule:
 		return "PatternRule"
 	default:
@@ -38,8 +44,35 @@ func (k RuleKind) String() string {
 type Program struct {
 	Rules     []Rule
 	Functions []ExprFunction
+	Imports   []ImportDecl
+	// Includes is only populated by a bare Parser.Parse(); ProgramLoader
+	// resolves each one into the included file's own Rules/Functions,
+	// merges them in, and clears this slice, so it's empty by the time an
+	// Evaluator sees the Program.
+	Includes []IncludeDecl
 }
 
+// ImportDecl is a top-level `import "path"` or `import name "path"`. Alias
+// is empty when the program didn't give it an explicit name, in which case
+// the evaluator derives one from Path.
+type ImportDecl struct {
+	token Token
+	Alias string
+	Path  string
+}
+
+func (d *ImportDecl) Token() Token { return d.token }
+
+// IncludeDecl is a top-level `include "path"` directive. The parser only
+// records it; ProgramLoader does the actual file reading, recursion and
+// merging, the same split of responsibility as import/resolveImports.
+type IncludeDecl struct {
+	token Token
+	Path  string
+}
+
+func (d *IncludeDecl) Token() Token { return d.token }
+
 type Rule struct {
 	Kind    RuleKind
 	Pattern Expr
@@ -69,8 +102,15 @@ type ObjectKeyValue struct {
 	Value Expr
 }
 
+// ExprUnary is a prefix operator (`-x`, `!x`) or, when OpToken is
+// PlusPlus/MinusMinus, one of the increment/decrement forms (`++x`, `x--`).
+// Target is only populated for the latter: buildAssignTarget resolves Expr's
+// lvalue once at parse time, the same AssignTarget an `=`/compound-assign
+// uses, so the evaluator can write the incremented value back without
+// re-deriving the target from Expr on every use.
 type ExprUnary struct {
 	Expr    Expr
+	Target  AssignTarget
 	OpToken Token
 	Postfix bool
 }
@@ -92,6 +132,15 @@ type ExprFunction struct {
 	Body  Statement
 }
 
+// ExprTernary is `Cond ? Then : Else`. Token() delegates to Cond so a
+// diagnostic anchored on the ternary points at its condition, matching how
+// ExprBinary points at Left.
+type ExprTernary struct {
+	Cond Expr
+	Then Expr
+	Else Expr
+}
+
 type ExprMatch struct {
 	token Token
 	Value Expr
@@ -100,18 +149,71 @@ type ExprMatch struct {
 
 type MatchCase struct {
 	Exprs []Expr
+	Guard Expr
 	Body  Statement
 }
 
-func (*ExprLiteral) exprNode()    {}
-func (*ExprIdentifier) exprNode() {}
-func (*ExprArray) exprNode()      {}
-func (*ExprObject) exprNode()     {}
-func (*ExprUnary) exprNode()      {}
-func (*ExprBinary) exprNode()     {}
-func (*ExprCall) exprNode()       {}
-func (*ExprFunction) exprNode()   {}
-func (*ExprMatch) exprNode()      {}
+// ExprRestPattern is the "...name" tail of an array pattern (`[x, ...tail]`)
+// or object pattern (`{ a, ...rest }`) in a match arm. It only has meaning
+// as a pattern; it captures whatever isn't bound by the other items into an
+// array or object.
+type ExprRestPattern struct {
+	token Token
+	Ident string
+}
+
+// PathSeg is one `.field` or `[expr]` link in an AssignTarget's Path. Field
+// is set for a dot access - it carries no lexeme of its own, GetString
+// resolves it against the token's Pos/Len the same way any other
+// identifier does - and Expr is set for a bracket access instead.
+type PathSeg struct {
+	Field Token
+	Expr  Expr
+}
+
+// AssignTarget is the resolved lvalue behind `=`, a compound assign, or
+// `++`/`--`: a base expression (almost always a bare identifier) plus the
+// chain of PathSeg accesses hung off it, e.g. `$.a[i].b` desugars to
+// {Obj: $, Path: [.a, [i], .b]}. buildAssignTarget builds this once at
+// parse time so the evaluator's assignToTarget doesn't have to re-walk an
+// arbitrary ExprBinary chain on every assignment.
+type AssignTarget struct {
+	Obj  Expr
+	Path []PathSeg
+}
+
+// ExprAssign is `target = value`. Target is resolved once at parse time by
+// buildAssignTarget, covering a plain identifier, and an identifier followed
+// by any chain of `.field`/`[expr]` accesses. Compound assignment
+// (`+=`, `-=`, ...) desugars into this same shape - see
+// Parser.rewriteCompundAssingment.
+type ExprAssign struct {
+	token  Token
+	Target AssignTarget
+	Value  Expr
+}
+
+// ExprRange is the `start:end` inside a computed member access used as a
+// slice (`arr[start:end]`), rather than a plain index. End is nil for the
+// open-ended form `arr[start:]`.
+type ExprRange struct {
+	Start Expr
+	End   Expr
+}
+
+func (*ExprLiteral) exprNode()     {}
+func (*ExprIdentifier) exprNode()  {}
+func (*ExprArray) exprNode()       {}
+func (*ExprObject) exprNode()      {}
+func (*ExprUnary) exprNode()       {}
+func (*ExprBinary) exprNode()      {}
+func (*ExprCall) exprNode()        {}
+func (*ExprFunction) exprNode()    {}
+func (*ExprTernary) exprNode()     {}
+func (*ExprMatch) exprNode()       {}
+func (*ExprRestPattern) exprNode() {}
+func (*ExprAssign) exprNode()      {}
+func (*ExprRange) exprNode()       {}
 
 func (expr *ExprLiteral) Token() Token    { return expr.token }
 func (expr *ExprIdentifier) Token() Token { return expr.token }
@@ -121,7 +223,11 @@ func (expr *ExprUnary) Token() Token      { return expr.OpToken }
 func (expr *ExprBinary) Token() Token     { return expr.Left.Token() }
 func (expr *ExprCall) Token() Token       { return expr.Func.Token() }
 func (expr *ExprFunction) Token() Token   { return expr.ident }
+func (expr *ExprAssign) Token() Token     { return expr.token }
+func (expr *ExprRange) Token() Token      { return expr.Start.Token() }
+func (expr *ExprTernary) Token() Token    { return expr.Cond.Token() }
 func (expr *ExprMatch) Token() Token      { return expr.token }
+func (expr *ExprRestPattern) Token() Token { return expr.token }
 
 type StatementBlock struct {
 	token Token
@@ -141,8 +247,18 @@ type StatementReturn struct {
 	Expr Expr
 }
 
+// StatementBreak is `break` or a labeled `break outer`. Label is empty for
+// the unlabeled form, which the innermost enclosing loop always consumes.
 type StatementBreak struct {
 	token Token
+	Label string
+}
+
+// StatementContinue is `continue` or a labeled `continue outer`, the
+// StatementBreak of the two - see its doc comment for Label.
+type StatementContinue struct {
+	token Token
+	Label string
 }
 
 type StatementIf struct {
@@ -151,9 +267,22 @@ type StatementIf struct {
 	ElseBody Statement
 }
 
+// StatementWhile's Label, when non-empty, is the name a `break`/`continue`
+// inside its body (or a nested loop's body) can target to act on this loop
+// specifically instead of its own innermost one.
 type StatementWhile struct {
-	Expr Expr
-	Body Statement
+	Expr  Expr
+	Body  Statement
+	Label string
+}
+
+// StatementDoWhile is `do { ... } while (expr)`: a post-test loop whose
+// body always runs at least once, since the condition isn't checked until
+// after the first iteration. Label mirrors StatementWhile's.
+type StatementDoWhile struct {
+	Expr  Expr
+	Body  Statement
+	Label string
 }
 
 type StatementFor struct {
@@ -161,30 +290,53 @@ type StatementFor struct {
 	Expr     Expr
 	PostExpr Expr
 	Body     Statement
+	Label    string
 }
 
 type StatementForIn struct {
-	Ident    *ExprIdentifier
-	Iterable Expr
-	Body     Statement
+	Ident      *ExprIdentifier
+	IndexIdent *ExprIdentifier
+	Iterable   Expr
+	Body       Statement
+	Label      string
+}
+
+// StatementNext is `next`: it stops the current pattern rule's action and
+// moves on to the next record, the same way AWK's `next` does.
+type StatementNext struct {
+	token Token
 }
 
-func (*StatementBlock) statementNode()  {}
-func (*StatementPrint) statementNode()  {}
-func (*StatementExpr) statementNode()   {}
-func (*StatementReturn) statementNode() {}
-func (*StatementBreak) statementNode()  {}
-func (*StatementIf) statementNode()     {}
-func (*StatementWhile) statementNode()  {}
-func (*StatementFor) statementNode()    {}
-func (*StatementForIn) statementNode()  {}
-
-func (stmt *StatementBlock) Token() Token  { return stmt.token }
-func (stmt *StatementPrint) Token() Token  { return stmt.token }
-func (stmt *StatementExpr) Token() Token   { return stmt.Expr.Token() }
-func (stmt *StatementReturn) Token() Token { return stmt.Expr.Token() }
-func (stmt *StatementBreak) Token() Token  { return stmt.token }
-func (stmt *StatementIf) Token() Token     { return stmt.Expr.Token() }
-func (stmt *StatementWhile) Token() Token  { return stmt.Expr.Token() }
-func (stmt *StatementFor) Token() Token    { return stmt.Expr.Token() }
-func (stmt *StatementForIn) Token() Token  { return stmt.Ident.Token() }
+// StatementExit is `exit`: it stops evaluating the whole program at once;
+// no remaining rule, ENDFILE or END block runs after it.
+type StatementExit struct {
+	token Token
+}
+
+func (*StatementBlock) statementNode()    {}
+func (*StatementPrint) statementNode()    {}
+func (*StatementExpr) statementNode()     {}
+func (*StatementReturn) statementNode()   {}
+func (*StatementBreak) statementNode()    {}
+func (*StatementContinue) statementNode() {}
+func (*StatementIf) statementNode()       {}
+func (*StatementWhile) statementNode()    {}
+func (*StatementDoWhile) statementNode()  {}
+func (*StatementFor) statementNode()      {}
+func (*StatementForIn) statementNode()    {}
+func (*StatementNext) statementNode()     {}
+func (*StatementExit) statementNode()     {}
+
+func (stmt *StatementBlock) Token() Token    { return stmt.token }
+func (stmt *StatementPrint) Token() Token    { return stmt.token }
+func (stmt *StatementExpr) Token() Token     { return stmt.Expr.Token() }
+func (stmt *StatementReturn) Token() Token   { return stmt.Expr.Token() }
+func (stmt *StatementBreak) Token() Token    { return stmt.token }
+func (stmt *StatementContinue) Token() Token { return stmt.token }
+func (stmt *StatementIf) Token() Token       { return stmt.Expr.Token() }
+func (stmt *StatementWhile) Token() Token    { return stmt.Expr.Token() }
+func (stmt *StatementDoWhile) Token() Token  { return stmt.Expr.Token() }
+func (stmt *StatementFor) Token() Token      { return stmt.Expr.Token() }
+func (stmt *StatementForIn) Token() Token    { return stmt.Ident.Token() }
+func (stmt *StatementNext) Token() Token     { return stmt.token }
+func (stmt *StatementExit) Token() Token     { return stmt.token }
@@ -0,0 +1,626 @@
+package lang
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Fdump writes an indented tree of n to w: one line per node giving its
+// type name, source position (resolved through fset) and - for nodes that
+// are really just a wrapped token (ExprIdentifier, ExprLiteral,
+// StatementBreak, ...) - the resolved lexeme, so `-dbg-ast` output reads
+// like a parse tree instead of go/ast.Print's generic, reflection-based
+// field dump of a Go syntax tree that was never meant to describe this
+// language. fset may be nil, in which case positions print as "@<pos>".
+//
+// n is usually a Node (Expr, Statement, or any individual AST type), but
+// Parser.Parse returns a bare Program, which doesn't implement Node itself
+// (it has no single Token to anchor on) - so Fdump also accepts a Program
+// directly as the whole-file entry point.
+func Fdump(w io.Writer, fset *FileSet, n any) error {
+	d := &dumper{w: w, fset: fset}
+	d.dumpNode(n, 0)
+	return d.err
+}
+
+type dumper struct {
+	w    io.Writer
+	fset *FileSet
+	err  error
+}
+
+func (d *dumper) writeIndent(depth int) {
+	for i := 0; i < depth && d.err == nil; i++ {
+		if _, err := io.WriteString(d.w, "  "); err != nil {
+			d.err = err
+		}
+	}
+}
+
+func (d *dumper) line(depth int, format string, args ...any) {
+	if d.err != nil {
+		return
+	}
+	d.writeIndent(depth)
+	if _, err := fmt.Fprintf(d.w, format, args...); err != nil {
+		d.err = err
+		return
+	}
+	if _, err := io.WriteString(d.w, "\n"); err != nil {
+		d.err = err
+	}
+}
+
+func (d *dumper) lexeme(tok Token) string {
+	if d.fset == nil {
+		return ""
+	}
+	return d.fset.Lexeme(tok)
+}
+
+func (d *dumper) pos(tok Token) string {
+	if d.fset == nil {
+		return fmt.Sprintf("@%d", tok.Pos)
+	}
+	f, line, col, _ := d.fset.Position(tok.Pos)
+	if f == nil {
+		return fmt.Sprintf("@%d", tok.Pos)
+	}
+	return fmt.Sprintf("%d:%d", line, col+1)
+}
+
+// dumpChild prints one field of the current node: a label, then the
+// child's own dumpNode output one level deeper. A nil child (an absent
+// ElseBody, a bare `for`'s missing PreExpr, ...) prints as "<nil>" instead
+// of being silently skipped, so the shape of the node is still visible.
+func (d *dumper) dumpChild(label string, child any, depth int) {
+	d.line(depth, "%s:", label)
+	if isNilNode(child) {
+		d.line(depth+1, "<nil>")
+		return
+	}
+	d.dumpNode(child, depth+1)
+}
+
+// dumpList prints a compact "label: [N]" header followed by each item
+// dumped one level deeper, for the Rules/Args/Items/Body-shaped fields.
+func (d *dumper) dumpList(label string, n int, depth int, item func(i int, depth int)) {
+	d.line(depth, "%s: [%d]", label, n)
+	for i := 0; i < n; i++ {
+		item(i, depth+1)
+	}
+}
+
+func isNilNode(n any) bool {
+	switch v := n.(type) {
+	case nil:
+		return true
+	case *ExprLiteral:
+		return v == nil
+	case *ExprIdentifier:
+		return v == nil
+	case *ExprArray:
+		return v == nil
+	case *ExprObject:
+		return v == nil
+	case *ExprUnary:
+		return v == nil
+	case *ExprBinary:
+		return v == nil
+	case *ExprTernary:
+		return v == nil
+	case *ExprCall:
+		return v == nil
+	case *ExprFunction:
+		return v == nil
+	case *ExprMatch:
+		return v == nil
+	case *ExprRestPattern:
+		return v == nil
+	case *StatementBlock:
+		return v == nil
+	case *StatementPrint:
+		return v == nil
+	case *StatementExpr:
+		return v == nil
+	case *StatementReturn:
+		return v == nil
+	case *StatementBreak:
+		return v == nil
+	case *StatementContinue:
+		return v == nil
+	case *StatementIf:
+		return v == nil
+	case *StatementWhile:
+		return v == nil
+	case *StatementDoWhile:
+		return v == nil
+	case *StatementFor:
+		return v == nil
+	case *StatementForIn:
+		return v == nil
+	default:
+		return false
+	}
+}
+
+func (d *dumper) dumpNode(n any, depth int) {
+	if isNilNode(n) {
+		d.line(depth, "<nil>")
+		return
+	}
+
+	if prog, ok := n.(Program); ok {
+		n = &prog
+	}
+
+	switch node := n.(type) {
+	case *Program:
+		d.line(depth, "Program")
+		d.dumpList("Includes", len(node.Includes), depth+1, func(i, depth int) {
+			d.line(depth, "IncludeDecl %s path=%q", d.pos(node.Includes[i].token), node.Includes[i].Path)
+		})
+		d.dumpList("Imports", len(node.Imports), depth+1, func(i, depth int) {
+			d.line(depth, "ImportDecl %s alias=%q path=%q", d.pos(node.Imports[i].token), node.Imports[i].Alias, node.Imports[i].Path)
+		})
+		d.dumpList("Functions", len(node.Functions), depth+1, func(i, depth int) {
+			d.dumpNode(&node.Functions[i], depth)
+		})
+		d.dumpList("Rules", len(node.Rules), depth+1, func(i, depth int) {
+			rule := node.Rules[i]
+			d.line(depth, "Rule kind=%s", rule.Kind)
+			if rule.Pattern != nil {
+				d.dumpChild("Pattern", rule.Pattern, depth+1)
+			}
+			d.dumpChild("Body", rule.Body, depth+1)
+		})
+
+	case *ExprLiteral:
+		d.line(depth, "ExprLiteral %s %s %q", d.pos(node.token), node.token.Tag, d.lexeme(node.token))
+	case *ExprIdentifier:
+		d.line(depth, "ExprIdentifier %s %q", d.pos(node.token), d.lexeme(node.token))
+	case *ExprArray:
+		d.line(depth, "ExprArray %s", d.pos(node.token))
+		d.dumpList("Items", len(node.Items), depth+1, func(i, depth int) {
+			d.dumpNode(node.Items[i], depth)
+		})
+	case *ExprObject:
+		d.line(depth, "ExprObject %s", d.pos(node.token))
+		d.dumpList("Items", len(node.Items), depth+1, func(i, depth int) {
+			d.line(depth, "ObjectKeyValue key=%q", node.Items[i].Key)
+			d.dumpChild("Value", node.Items[i].Value, depth+1)
+		})
+	case *ExprUnary:
+		d.line(depth, "ExprUnary %s op=%s postfix=%t", d.pos(node.OpToken), node.OpToken.Tag, node.Postfix)
+		d.dumpChild("Expr", node.Expr, depth+1)
+	case *ExprBinary:
+		d.line(depth, "ExprBinary %s op=%s", d.pos(node.OpToken), node.OpToken.Tag)
+		d.dumpChild("Left", node.Left, depth+1)
+		d.dumpChild("Right", node.Right, depth+1)
+	case *ExprTernary:
+		d.line(depth, "ExprTernary")
+		d.dumpChild("Cond", node.Cond, depth+1)
+		d.dumpChild("Then", node.Then, depth+1)
+		d.dumpChild("Else", node.Else, depth+1)
+	case *ExprCall:
+		d.line(depth, "ExprCall")
+		d.dumpChild("Func", node.Func, depth+1)
+		d.dumpList("Args", len(node.Args), depth+1, func(i, depth int) {
+			d.dumpNode(node.Args[i], depth)
+		})
+	case *ExprFunction:
+		d.line(depth, "ExprFunction %s name=%q args=%v", d.pos(node.ident), d.lexeme(node.ident), node.Args)
+		d.dumpChild("Body", node.Body, depth+1)
+	case *ExprMatch:
+		d.line(depth, "ExprMatch %s", d.pos(node.token))
+		d.dumpChild("Value", node.Value, depth+1)
+		d.dumpList("Cases", len(node.Cases), depth+1, func(i, depth int) {
+			c := node.Cases[i]
+			d.line(depth, "MatchCase")
+			d.dumpList("Exprs", len(c.Exprs), depth+1, func(i, depth int) {
+				d.dumpNode(c.Exprs[i], depth)
+			})
+			if c.Guard != nil {
+				d.dumpChild("Guard", c.Guard, depth+1)
+			}
+			d.dumpChild("Body", c.Body, depth+1)
+		})
+	case *ExprRestPattern:
+		d.line(depth, "ExprRestPattern %s ident=%q", d.pos(node.token), node.Ident)
+
+	case *StatementBlock:
+		d.line(depth, "StatementBlock %s", d.pos(node.token))
+		d.dumpList("Body", len(node.Body), depth+1, func(i, depth int) {
+			d.dumpNode(node.Body[i], depth)
+		})
+	case *StatementPrint:
+		d.line(depth, "StatementPrint %s", d.pos(node.token))
+		d.dumpList("Args", len(node.Args), depth+1, func(i, depth int) {
+			d.dumpNode(node.Args[i], depth)
+		})
+	case *StatementExpr:
+		d.line(depth, "StatementExpr")
+		d.dumpChild("Expr", node.Expr, depth+1)
+	case *StatementReturn:
+		d.line(depth, "StatementReturn")
+		if node.Expr != nil {
+			d.dumpChild("Expr", node.Expr, depth+1)
+		}
+	case *StatementBreak:
+		d.line(depth, "StatementBreak %s label=%q", d.pos(node.token), node.Label)
+	case *StatementContinue:
+		d.line(depth, "StatementContinue %s label=%q", d.pos(node.token), node.Label)
+	case *StatementIf:
+		d.line(depth, "StatementIf")
+		d.dumpChild("Expr", node.Expr, depth+1)
+		d.dumpChild("Body", node.Body, depth+1)
+		if node.ElseBody != nil {
+			d.dumpChild("ElseBody", node.ElseBody, depth+1)
+		}
+	case *StatementWhile:
+		d.line(depth, "StatementWhile label=%q", node.Label)
+		d.dumpChild("Expr", node.Expr, depth+1)
+		d.dumpChild("Body", node.Body, depth+1)
+	case *StatementDoWhile:
+		d.line(depth, "StatementDoWhile label=%q", node.Label)
+		d.dumpChild("Body", node.Body, depth+1)
+		d.dumpChild("Expr", node.Expr, depth+1)
+	case *StatementFor:
+		d.line(depth, "StatementFor label=%q", node.Label)
+		if node.PreExpr != nil {
+			d.dumpChild("PreExpr", node.PreExpr, depth+1)
+		}
+		d.dumpChild("Expr", node.Expr, depth+1)
+		if node.PostExpr != nil {
+			d.dumpChild("PostExpr", node.PostExpr, depth+1)
+		}
+		d.dumpChild("Body", node.Body, depth+1)
+	case *StatementForIn:
+		d.line(depth, "StatementForIn label=%q", node.Label)
+		d.dumpChild("Ident", node.Ident, depth+1)
+		if node.IndexIdent != nil {
+			d.dumpChild("IndexIdent", node.IndexIdent, depth+1)
+		}
+		d.dumpChild("Iterable", node.Iterable, depth+1)
+		d.dumpChild("Body", node.Body, depth+1)
+
+	default:
+		if node, ok := n.(Node); ok {
+			d.line(depth, "%T %s", n, d.pos(node.Token()))
+		} else {
+			d.line(depth, "%T (not a Node)", n)
+		}
+	}
+}
+
+// Fprint renders n back to jqawk-ish source syntax. Original formatting and
+// comments are gone once a program has been lexed, so this isn't a
+// byte-for-byte round trip - it's a plain, consistently-indented rendering
+// good enough to use as a quick formatter or to read a rewritten AST back
+// as a program.
+func Fprint(w io.Writer, fset *FileSet, n any) error {
+	p := &printer{w: w, fset: fset}
+	if prog, ok := n.(Program); ok {
+		p.printProgram(&prog)
+	} else if prog, ok := n.(*Program); ok {
+		p.printProgram(prog)
+	} else {
+		p.printExprOrStatement(n)
+	}
+	return p.err
+}
+
+type printer struct {
+	w      io.Writer
+	fset   *FileSet
+	err    error
+	indent int
+}
+
+func (p *printer) write(s string) {
+	if p.err != nil {
+		return
+	}
+	if _, err := io.WriteString(p.w, s); err != nil {
+		p.err = err
+	}
+}
+
+func (p *printer) writef(format string, args ...any) {
+	p.write(fmt.Sprintf(format, args...))
+}
+
+func (p *printer) writeIndent() {
+	for i := 0; i < p.indent; i++ {
+		p.write("  ")
+	}
+}
+
+func (p *printer) lexeme(tok Token) string {
+	if p.fset == nil {
+		return ""
+	}
+	return p.fset.Lexeme(tok)
+}
+
+// operatorText renders a binary OpToken's tag as jqawk source text.
+// TokenTag.String() already does this for every symbolic operator (it's
+// the stringer-generated linecomment, e.g. "==" or "~"), but a few
+// operators are keywords instead of symbols and their tag name isn't
+// spelled the same as the keyword (Is -> "is").
+func operatorText(tag TokenTag) string {
+	switch tag {
+	case Is:
+		return "is"
+	default:
+		return tag.String()
+	}
+}
+
+func (p *printer) printProgram(prog *Program) {
+	for _, inc := range prog.Includes {
+		p.writef("include %q\n", inc.Path)
+	}
+	for _, imp := range prog.Imports {
+		if imp.Alias != "" {
+			p.writef("import %s %q\n", imp.Alias, imp.Path)
+		} else {
+			p.writef("import %q\n", imp.Path)
+		}
+	}
+	for i := range prog.Functions {
+		p.printExpr(&prog.Functions[i])
+		p.write("\n")
+	}
+	for _, rule := range prog.Rules {
+		switch rule.Kind {
+		case BeginRule:
+			p.write("BEGIN ")
+		case EndRule:
+			p.write("END ")
+		case BeginFileRule:
+			p.write("BEGINFILE ")
+		case EndFileRule:
+			p.write("ENDFILE ")
+		default:
+			if rule.Pattern != nil {
+				p.printExpr(rule.Pattern)
+				p.write(" ")
+			}
+		}
+		p.printStatement(rule.Body)
+		p.write("\n")
+	}
+}
+
+func (p *printer) printExprOrStatement(n any) {
+	switch node := n.(type) {
+	case Expr:
+		p.printExpr(node)
+	case Statement:
+		p.printStatement(node)
+	default:
+		p.writef("/* cannot print %T */", n)
+	}
+}
+
+// printExpr renders an expression inline (no trailing newline, no
+// indentation of its own - callers that need a statement's leading indent
+// write it before calling in).
+func (p *printer) printExpr(expr Expr) {
+	if expr == nil || isNilNode(expr) {
+		return
+	}
+
+	switch e := expr.(type) {
+	case *ExprLiteral:
+		switch e.token.Tag {
+		case Str:
+			p.writef("%q", p.lexeme(e.token))
+		case Regex:
+			p.writef("/%s/%s", p.lexeme(e.token), e.token.Flags)
+		default:
+			p.write(p.lexeme(e.token))
+		}
+	case *ExprIdentifier:
+		p.write(p.lexeme(e.token))
+	case *ExprArray:
+		p.write("[")
+		for i, item := range e.Items {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.printExpr(item)
+		}
+		p.write("]")
+	case *ExprObject:
+		p.write("{ ")
+		for i, item := range e.Items {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.writef("%s: ", item.Key)
+			p.printExpr(item.Value)
+		}
+		p.write(" }")
+	case *ExprUnary:
+		op := e.OpToken.Tag.String()
+		if e.Postfix {
+			p.printExpr(e.Expr)
+			p.write(op)
+		} else {
+			p.write(op)
+			p.printExpr(e.Expr)
+		}
+	case *ExprBinary:
+		switch e.OpToken.Tag {
+		case Dot:
+			p.printExpr(e.Left)
+			p.write(".")
+			p.printExpr(e.Right)
+		case LSquare:
+			p.printExpr(e.Left)
+			p.write("[")
+			p.printExpr(e.Right)
+			p.write("]")
+		default:
+			p.printExpr(e.Left)
+			p.writef(" %s ", operatorText(e.OpToken.Tag))
+			p.printExpr(e.Right)
+		}
+	case *ExprTernary:
+		p.printExpr(e.Cond)
+		p.write(" ? ")
+		p.printExpr(e.Then)
+		p.write(" : ")
+		p.printExpr(e.Else)
+	case *ExprCall:
+		p.printExpr(e.Func)
+		p.write("(")
+		for i, arg := range e.Args {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.printExpr(arg)
+		}
+		p.write(")")
+	case *ExprFunction:
+		p.writef("function %s(%s) ", p.lexeme(e.ident), strings.Join(e.Args, ", "))
+		p.printStatement(e.Body)
+	case *ExprMatch:
+		p.write("match ")
+		p.printExpr(e.Value)
+		p.write(" {\n")
+		p.indent++
+		for _, c := range e.Cases {
+			p.writeIndent()
+			for i, pat := range c.Exprs {
+				if i > 0 {
+					p.write(" | ")
+				}
+				p.printExpr(pat)
+			}
+			if c.Guard != nil {
+				p.write(" if ")
+				p.printExpr(c.Guard)
+			}
+			p.write(" => ")
+			p.printStatement(c.Body)
+			p.write("\n")
+		}
+		p.indent--
+		p.writeIndent()
+		p.write("}")
+	case *ExprRestPattern:
+		p.writef("...%s", e.Ident)
+	default:
+		p.writef("/* %T */", expr)
+	}
+}
+
+// printStatement renders a statement at the printer's current indent
+// level. Block statements always render with braces; printStatement
+// doesn't add a leading indent of its own so it can follow "if (...) " etc.
+// on the same line.
+func (p *printer) printStatement(stmt Statement) {
+	if stmt == nil || isNilNode(stmt) {
+		p.write("{}")
+		return
+	}
+
+	switch s := stmt.(type) {
+	case *StatementBlock:
+		p.write("{\n")
+		p.indent++
+		for _, inner := range s.Body {
+			p.writeIndent()
+			p.printStatement(inner)
+			p.write("\n")
+		}
+		p.indent--
+		p.writeIndent()
+		p.write("}")
+	case *StatementPrint:
+		p.write("print")
+		for i, arg := range s.Args {
+			if i > 0 {
+				p.write(",")
+			}
+			p.write(" ")
+			p.printExpr(arg)
+		}
+	case *StatementExpr:
+		p.printExpr(s.Expr)
+	case *StatementReturn:
+		p.write("return")
+		if s.Expr != nil {
+			p.write(" ")
+			p.printExpr(s.Expr)
+		}
+	case *StatementBreak:
+		p.write("break")
+		if s.Label != "" {
+			p.writef(" %s", s.Label)
+		}
+	case *StatementContinue:
+		p.write("continue")
+		if s.Label != "" {
+			p.writef(" %s", s.Label)
+		}
+	case *StatementIf:
+		p.write("if (")
+		p.printExpr(s.Expr)
+		p.write(") ")
+		p.printStatement(s.Body)
+		if s.ElseBody != nil {
+			p.write(" else ")
+			p.printStatement(s.ElseBody)
+		}
+	case *StatementWhile:
+		if s.Label != "" {
+			p.writef("%s: ", s.Label)
+		}
+		p.write("while (")
+		p.printExpr(s.Expr)
+		p.write(") ")
+		p.printStatement(s.Body)
+	case *StatementDoWhile:
+		if s.Label != "" {
+			p.writef("%s: ", s.Label)
+		}
+		p.write("do ")
+		p.printStatement(s.Body)
+		p.write(" while (")
+		p.printExpr(s.Expr)
+		p.write(")")
+	case *StatementFor:
+		if s.Label != "" {
+			p.writef("%s: ", s.Label)
+		}
+		p.write("for (")
+		p.printExpr(s.PreExpr)
+		p.write("; ")
+		p.printExpr(s.Expr)
+		p.write("; ")
+		p.printExpr(s.PostExpr)
+		p.write(") ")
+		p.printStatement(s.Body)
+	case *StatementForIn:
+		if s.Label != "" {
+			p.writef("%s: ", s.Label)
+		}
+		p.write("for (")
+		p.printExpr(s.Ident)
+		if s.IndexIdent != nil {
+			p.write(", ")
+			p.printExpr(s.IndexIdent)
+		}
+		p.write(" in ")
+		p.printExpr(s.Iterable)
+		p.write(") ")
+		p.printStatement(s.Body)
+	default:
+		p.writef("/* %T */", stmt)
+	}
+}
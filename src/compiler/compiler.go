@@ -0,0 +1,288 @@
+// Package compiler turns a parsed jqawk program into a self-contained Go
+// source file (the `jqawk build` subcommand), inspired by the now-removed
+// awkgo sub-tool from the goawk project.
+//
+// This first pass only covers BEGIN/END blocks built from the AST nodes
+// literals, arrays/objects, unary/binary expressions, calls to a handful of
+// builtins, and print/expr/if statements compile down to. Pattern rules,
+// loops, user-defined functions and assignment (AssignTarget/ExprAssign/
+// ExprRange) aren't handled by this pass yet, so Compile reports them as
+// unsupported instead of silently emitting something that drifts from the
+// interpreter. Widen this once codegen for those nodes lands, along with the
+// type-inference pass that would let simple cases compile to plain
+// float64/string instead of the boxed Value.
+package compiler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	lang "github.com/alligator/jqawk/src"
+)
+
+// Compile walks prog (parsed from src by lex) and returns a standalone Go
+// source file, in packageName, that behaves like lang.EvalProgram run
+// against the same BEGIN/END blocks.
+func Compile(prog *lang.Program, lex *lang.Lexer, packageName string) (string, error) {
+	if len(prog.Functions) > 0 {
+		return "", fmt.Errorf("compiler: user-defined functions aren't supported yet")
+	}
+
+	c := &compiler{lex: lex}
+
+	var begins, ends strings.Builder
+	for i, rule := range prog.Rules {
+		switch rule.Kind {
+		case lang.BeginRule:
+			name := fmt.Sprintf("begin%d", i)
+			if err := c.compileBlockFunc(name, rule.Body); err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&begins, "\t%s()\n", name)
+		case lang.EndRule:
+			name := fmt.Sprintf("end%d", i)
+			if err := c.compileBlockFunc(name, rule.Body); err != nil {
+				return "", err
+			}
+			fmt.Fprintf(&ends, "\t%s()\n", name)
+		case lang.PatternRule:
+			return "", fmt.Errorf("compiler: pattern rules aren't supported yet (rule %d)", i)
+		default:
+			return "", fmt.Errorf("compiler: unsupported rule kind %s", rule.Kind)
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("package " + packageName + "\n\n")
+	out.WriteString(runtimeSource)
+	out.WriteString("\n")
+	out.WriteString(c.funcs.String())
+	out.WriteString("\nfunc main() {\n")
+	out.WriteString(begins.String())
+	out.WriteString(ends.String())
+	out.WriteString("}\n")
+
+	return out.String(), nil
+}
+
+type compiler struct {
+	lex   *lang.Lexer
+	funcs strings.Builder
+}
+
+func indent(n int) string {
+	return strings.Repeat("\t", n)
+}
+
+func (c *compiler) compileBlockFunc(name string, body lang.Statement) error {
+	var buf strings.Builder
+	if err := c.compileStatement(&buf, body, 1); err != nil {
+		return err
+	}
+	fmt.Fprintf(&c.funcs, "func %s() {\n%s}\n\n", name, buf.String())
+	return nil
+}
+
+func (c *compiler) compileStatement(out *strings.Builder, stmt lang.Statement, depth int) error {
+	switch st := stmt.(type) {
+	case *lang.StatementBlock:
+		for _, s := range st.Body {
+			if err := c.compileStatement(out, s, depth); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *lang.StatementPrint:
+		if len(st.Args) == 0 {
+			fmt.Fprintf(out, "%sfmt.Println()\n", indent(depth))
+			return nil
+		}
+		parts := make([]string, 0, len(st.Args))
+		for _, arg := range st.Args {
+			expr, err := c.compileExpr(arg)
+			if err != nil {
+				return err
+			}
+			parts = append(parts, expr+".PrettyString()")
+		}
+		fmt.Fprintf(out, "%sfmt.Println(strings.Join([]string{%s}, \" \"))\n", indent(depth), strings.Join(parts, ", "))
+		return nil
+	case *lang.StatementExpr:
+		expr, err := c.compileExpr(st.Expr)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s_ = %s\n", indent(depth), expr)
+		return nil
+	case *lang.StatementIf:
+		cond, err := c.compileExpr(st.Expr)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%sif %s.Truthy() {\n", indent(depth), cond)
+		if err := c.compileStatement(out, st.Body, depth+1); err != nil {
+			return err
+		}
+		if st.ElseBody != nil {
+			fmt.Fprintf(out, "%s} else {\n", indent(depth))
+			if err := c.compileStatement(out, st.ElseBody, depth+1); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(out, "%s}\n", indent(depth))
+		return nil
+	case *lang.StatementReturn:
+		return fmt.Errorf("compiler: return is only valid inside a function, which isn't supported yet")
+	case *lang.StatementBreak:
+		return fmt.Errorf("compiler: break is only valid inside a loop, which isn't supported yet")
+	case *lang.StatementWhile, *lang.StatementDoWhile, *lang.StatementFor, *lang.StatementForIn:
+		return fmt.Errorf("compiler: loops aren't supported yet (they need an assignment target)")
+	default:
+		return fmt.Errorf("compiler: unsupported statement %T", stmt)
+	}
+}
+
+func (c *compiler) compileExpr(expr lang.Expr) (string, error) {
+	switch ex := expr.(type) {
+	case *lang.ExprLiteral:
+		return c.compileLiteral(ex)
+	case *lang.ExprArray:
+		items := make([]string, 0, len(ex.Items))
+		for _, item := range ex.Items {
+			s, err := c.compileExpr(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return fmt.Sprintf("NewArray(%s)", strings.Join(items, ", ")), nil
+	case *lang.ExprObject:
+		entries := make([]string, 0, len(ex.Items))
+		for _, kv := range ex.Items {
+			s, err := c.compileExpr(kv.Value)
+			if err != nil {
+				return "", err
+			}
+			entries = append(entries, fmt.Sprintf("Entry(%s, %s)", strconv.Quote(kv.Key), s))
+		}
+		return fmt.Sprintf("NewObject(%s)", strings.Join(entries, ", ")), nil
+	case *lang.ExprUnary:
+		return c.compileUnary(ex)
+	case *lang.ExprBinary:
+		return c.compileBinary(ex)
+	case *lang.ExprCall:
+		return c.compileCall(ex)
+	default:
+		return "", fmt.Errorf("compiler: unsupported expression %T", expr)
+	}
+}
+
+func (c *compiler) compileLiteral(expr *lang.ExprLiteral) (string, error) {
+	token := expr.Token()
+	switch token.Tag {
+	case lang.Str:
+		// decode the same escapes the interpreter does (\n, \t, \\) so the Go
+		// literal holds the string the interpreter would have built
+		str := strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\t`, "\t").Replace(c.lex.GetString(&token))
+		return fmt.Sprintf("NewStr(%s)", strconv.Quote(str)), nil
+	case lang.Num:
+		return fmt.Sprintf("NewNum(%s)", c.lex.GetString(&token)), nil
+	case lang.True:
+		return "NewBool(true)", nil
+	case lang.False:
+		return "NewBool(false)", nil
+	case lang.Regex:
+		return fmt.Sprintf("NewRegex(%s)", strconv.Quote(c.lex.GetString(&token))), nil
+	default:
+		return "", fmt.Errorf("compiler: unsupported literal token %s", token.Tag)
+	}
+}
+
+func (c *compiler) compileUnary(expr *lang.ExprUnary) (string, error) {
+	if expr.Postfix || expr.OpToken.Tag == lang.PlusPlus || expr.OpToken.Tag == lang.MinusMinus {
+		return "", fmt.Errorf("compiler: ++/-- aren't supported yet (they need an assignment target)")
+	}
+
+	inner, err := c.compileExpr(expr.Expr)
+	if err != nil {
+		return "", err
+	}
+
+	switch expr.OpToken.Tag {
+	case lang.Bang:
+		return fmt.Sprintf("%s.Not()", inner), nil
+	case lang.Minus:
+		return fmt.Sprintf("%s.Negate()", inner), nil
+	default:
+		return "", fmt.Errorf("compiler: unsupported unary operator %s", expr.OpToken.Tag)
+	}
+}
+
+var binaryMethods = map[lang.TokenTag]string{
+	lang.Plus:         "Add",
+	lang.Minus:        "Sub",
+	lang.Multiply:     "Mul",
+	lang.Divide:       "Div",
+	lang.Percent:      "Mod",
+	lang.LessThan:     "Lt",
+	lang.GreaterThan:  "Gt",
+	lang.LessEqual:    "Lte",
+	lang.GreaterEqual: "Gte",
+	lang.EqualEqual:   "Eq",
+	lang.BangEqual:    "Neq",
+	lang.AmpAmp:       "And",
+	lang.PipePipe:     "Or",
+	lang.Tilde:        "Match",
+	lang.BangTilde:    "NotMatch",
+}
+
+func (c *compiler) compileBinary(expr *lang.ExprBinary) (string, error) {
+	left, err := c.compileExpr(expr.Left)
+	if err != nil {
+		return "", err
+	}
+	right, err := c.compileExpr(expr.Right)
+	if err != nil {
+		return "", err
+	}
+
+	method, ok := binaryMethods[expr.OpToken.Tag]
+	if !ok {
+		return "", fmt.Errorf("compiler: unsupported binary operator %s", expr.OpToken.Tag)
+	}
+
+	return fmt.Sprintf("%s.%s(%s)", left, method, right), nil
+}
+
+// builtinCalls maps the jqawk native functions the compiler knows how to
+// call to the corresponding runtime helper name.
+var builtinCalls = map[string]string{
+	"printf":  "Printf",
+	"sprintf": "Sprintf",
+}
+
+func (c *compiler) compileCall(expr *lang.ExprCall) (string, error) {
+	ident, ok := expr.Func.(*lang.ExprIdentifier)
+	if !ok {
+		return "", fmt.Errorf("compiler: only calls to a known builtin are supported so far")
+	}
+
+	identToken := ident.Token()
+	name := c.lex.GetString(&identToken)
+	fn, ok := builtinCalls[name]
+	if !ok {
+		return "", fmt.Errorf("compiler: unsupported call to %q (only printf/sprintf are implemented so far)", name)
+	}
+
+	args := make([]string, 0, len(expr.Args))
+	for _, arg := range expr.Args {
+		s, err := c.compileExpr(arg)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, s)
+	}
+
+	return fmt.Sprintf("%s(%s)", fn, strings.Join(args, ", ")), nil
+}
@@ -0,0 +1,256 @@
+package compiler
+
+// runtimeSource is the small, vendored runtime pasted verbatim into every
+// compiled program: a boxed Value type plus the handful of operators and
+// builtins the compiler currently emits calls to. It only depends on the
+// standard library so `go build` on the generated file needs nothing else.
+const runtimeSource = `import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Kind int
+
+const (
+	KindNil Kind = iota
+	KindStr
+	KindNum
+	KindBool
+	KindArr
+	KindObj
+)
+
+type Value struct {
+	Kind Kind
+	Str  string
+	Num  float64
+	Bool bool
+	Arr  []Value
+	Obj  map[string]Value
+	Keys []string
+}
+
+func NewNil() Value                { return Value{Kind: KindNil} }
+func NewStr(s string) Value        { return Value{Kind: KindStr, Str: s} }
+func NewNum(n float64) Value       { return Value{Kind: KindNum, Num: n} }
+func NewBool(b bool) Value         { return Value{Kind: KindBool, Bool: b} }
+func NewRegex(pattern string) Value { return Value{Kind: KindStr, Str: pattern} }
+
+func NewArray(items ...Value) Value {
+	return Value{Kind: KindArr, Arr: items}
+}
+
+type objectEntry struct {
+	Key   string
+	Value Value
+}
+
+func Entry(key string, val Value) objectEntry {
+	return objectEntry{key, val}
+}
+
+func NewObject(entries ...objectEntry) Value {
+	obj := make(map[string]Value, len(entries))
+	keys := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if _, exists := obj[e.Key]; !exists {
+			keys = append(keys, e.Key)
+		}
+		obj[e.Key] = e.Value
+	}
+	return Value{Kind: KindObj, Obj: obj, Keys: keys}
+}
+
+func (v Value) asFloat64() float64 {
+	switch v.Kind {
+	case KindNum:
+		return v.Num
+	case KindBool:
+		if v.Bool {
+			return 1
+		}
+		return 0
+	case KindStr:
+		n, err := strconv.ParseFloat(v.Str, 64)
+		if err != nil {
+			return 0
+		}
+		return n
+	}
+	return 0
+}
+
+func (v Value) String() string {
+	switch v.Kind {
+	case KindStr:
+		return v.Str
+	case KindNum:
+		return strconv.FormatFloat(v.Num, 'f', -1, 64)
+	}
+	return ""
+}
+
+func (v Value) Truthy() bool {
+	switch v.Kind {
+	case KindBool:
+		return v.Bool
+	case KindNum:
+		return v.Num != 0
+	case KindStr:
+		return len(v.Str) > 0
+	case KindArr, KindObj:
+		return true
+	}
+	return false
+}
+
+func (v Value) prettyQuoted() string {
+	if v.Kind == KindStr {
+		return strconv.Quote(v.Str)
+	}
+	return v.PrettyString()
+}
+
+func (v Value) PrettyString() string {
+	switch v.Kind {
+	case KindStr:
+		return v.Str
+	case KindNum:
+		return strconv.FormatFloat(v.Num, 'f', -1, 64)
+	case KindBool:
+		if v.Bool {
+			return "true"
+		}
+		return "false"
+	case KindArr:
+		parts := make([]string, len(v.Arr))
+		for i, item := range v.Arr {
+			parts[i] = item.prettyQuoted()
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case KindObj:
+		parts := make([]string, 0, len(v.Keys))
+		for _, k := range v.Keys {
+			parts = append(parts, strconv.Quote(k)+": "+v.Obj[k].prettyQuoted())
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	default:
+		return "null"
+	}
+}
+
+func (v Value) Not() Value    { return NewBool(!v.Truthy()) }
+func (v Value) Negate() Value { return NewNum(-v.asFloat64()) }
+
+func (v Value) Add(other Value) Value {
+	if v.Kind == KindStr || other.Kind == KindStr {
+		return NewStr(v.String() + other.String())
+	}
+	return NewNum(v.asFloat64() + other.asFloat64())
+}
+
+func (v Value) Sub(other Value) Value { return NewNum(v.asFloat64() - other.asFloat64()) }
+func (v Value) Mul(other Value) Value { return NewNum(v.asFloat64() * other.asFloat64()) }
+func (v Value) Div(other Value) Value { return NewNum(v.asFloat64() / other.asFloat64()) }
+func (v Value) Mod(other Value) Value {
+	return NewNum(float64(int64(v.asFloat64()) % int64(other.asFloat64())))
+}
+
+func (v Value) compare(other Value) int {
+	if v.Kind == KindStr && other.Kind == KindStr {
+		return strings.Compare(v.Str, other.Str)
+	}
+	a, b := v.asFloat64(), other.asFloat64()
+	switch {
+	case a > b:
+		return 1
+	case a < b:
+		return -1
+	default:
+		return 0
+	}
+}
+
+func (v Value) Lt(other Value) Value  { return NewBool(v.compare(other) < 0) }
+func (v Value) Gt(other Value) Value  { return NewBool(v.compare(other) > 0) }
+func (v Value) Lte(other Value) Value { return NewBool(v.compare(other) <= 0) }
+func (v Value) Gte(other Value) Value { return NewBool(v.compare(other) >= 0) }
+func (v Value) Eq(other Value) Value  { return NewBool(v.compare(other) == 0) }
+func (v Value) Neq(other Value) Value { return NewBool(v.compare(other) != 0) }
+
+func (v Value) And(other Value) Value { return NewBool(v.Truthy() && other.Truthy()) }
+func (v Value) Or(other Value) Value  { return NewBool(v.Truthy() || other.Truthy()) }
+
+func (v Value) Match(pattern Value) Value {
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return NewBool(false)
+	}
+	return NewBool(re.MatchString(v.String()))
+}
+
+func (v Value) NotMatch(pattern Value) Value {
+	return v.Match(pattern).Not()
+}
+
+// asInterfaceFor converts v into the Go type fmt expects for the given
+// printf verb, so formatPrintf can delegate to fmt.Sprintf instead of
+// reimplementing directive parsing.
+func (v Value) asInterfaceFor(verb byte) interface{} {
+	switch verb {
+	case 'd', 'x', 'X', 'o', 'c':
+		return int64(v.asFloat64())
+	case 'f', 'F', 'e', 'E', 'g', 'G':
+		return v.asFloat64()
+	case 's':
+		return v.String()
+	default:
+		return v.PrettyString()
+	}
+}
+
+func formatPrintf(format string, args []Value) string {
+	conv := make([]interface{}, 0, len(args))
+	argIdx := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i == len(format)-1 {
+			continue
+		}
+		j := i + 1
+		for j < len(format) && strings.ContainsRune("-+ 0#.0123456789", rune(format[j])) {
+			j++
+		}
+		if j >= len(format) {
+			break
+		}
+		verb := format[j]
+		i = j
+		if verb == '%' {
+			continue
+		}
+		if argIdx >= len(args) {
+			continue
+		}
+		conv = append(conv, args[argIdx].asInterfaceFor(verb))
+		argIdx++
+	}
+	return fmt.Sprintf(format, conv...)
+}
+
+func Printf(args ...Value) Value {
+	if len(args) == 0 {
+		return NewNil()
+	}
+	fmt.Print(formatPrintf(args[0].String(), args[1:]))
+	return NewNil()
+}
+
+func Sprintf(args ...Value) Value {
+	if len(args) == 0 {
+		return NewStr("")
+	}
+	return NewStr(formatPrintf(args[0].String(), args[1:]))
+}
+`
@@ -0,0 +1,117 @@
+package lang
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// encodeCSV renders rows - an array of arrays, or an array of objects
+// sharing the same keys - as CSV text. Object rows use the first row's keys
+// as the header.
+func encodeCSV(rows *Value) (string, error) {
+	if rows.Tag != ValueArray {
+		return "", fmt.Errorf("expected an array of rows")
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	var header []string
+	for i, rowCell := range rows.Array {
+		row := rowCell.Value
+		switch row.Tag {
+		case ValueArray:
+			record := make([]string, 0, len(row.Array))
+			for _, field := range row.Array {
+				record = append(record, field.Value.String())
+			}
+			if err := w.Write(record); err != nil {
+				return "", err
+			}
+		case ValueObj:
+			if i == 0 {
+				header = row.ObjKeys
+				if err := w.Write(header); err != nil {
+					return "", err
+				}
+			}
+			record := make([]string, 0, len(header))
+			for _, key := range header {
+				if cell, ok := (*row.Obj)[key]; ok {
+					record = append(record, cell.Value.String())
+				} else {
+					record = append(record, "")
+				}
+			}
+			if err := w.Write(record); err != nil {
+				return "", err
+			}
+		default:
+			return "", fmt.Errorf("expected each row to be an array or object")
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// parseCSV decodes CSV text into an array of rows, either arrays of field
+// strings or (with hasHeader) objects keyed by the first row.
+func parseCSV(src string, hasHeader bool) (Value, error) {
+	r := csv.NewReader(strings.NewReader(src))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return Value{}, err
+	}
+
+	var header []string
+	if hasHeader && len(records) > 0 {
+		header = records[0]
+		records = records[1:]
+	}
+
+	rows := NewArray()
+	for _, record := range records {
+		rows.Array = append(rows.Array, NewCell(buildCsvRow(record, header)))
+	}
+
+	return rows, nil
+}
+
+// buildCsvRow turns one CSV/TSV record into a row Value. With no header,
+// that's a plain array of fields, indexed positionally ($[0]). With a
+// header, it's an object keyed by header name ($["Country"] or $.Country) -
+// but each field is also reachable by its positional index, so the same
+// `$[0] == ... { print $[2] }`-style rule works whether or not the file
+// happens to have a header row. The numeric keys are left out of ObjKeys so
+// they don't show up as duplicate fields when the row is iterated or
+// re-encoded as JSON.
+func buildCsvRow(record []string, header []string) Value {
+	if header == nil {
+		row := NewArray()
+		for _, field := range record {
+			row.Array = append(row.Array, NewCell(NewValue(field)))
+		}
+		return row
+	}
+
+	obj := NewObject()
+	for i, key := range header {
+		var field string
+		if i < len(record) {
+			field = record[i]
+		}
+		cell := NewCell(NewValue(field))
+		(*obj.Obj)[key] = cell
+		obj.ObjKeys = append(obj.ObjKeys, key)
+		(*obj.Obj)[strconv.Itoa(i)] = cell
+	}
+	return obj
+}
@@ -0,0 +1,190 @@
+package lang
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// Decoder produces one root Value at a time from an input file. jsonParser
+// was the only implementation until multi-format input landed; every format
+// now streams through this same interface so BEGINFILE/ENDFILE/$file
+// semantics stay identical regardless of what's being decoded.
+type Decoder interface {
+	Next() (Value, error)
+}
+
+// InputFormatOverride is set by the CLI's -F flag; when empty, each input
+// file's format is auto-detected from its name's extension (DetectFormat).
+var InputFormatOverride string
+
+// CSVHasHeader is set by the CLI's --csv-header flag and controls whether
+// CSV/TSV input decodes rows as objects (keyed by the first row) or arrays.
+var CSVHasHeader bool
+
+// CSVDelimiter is set by the CLI's -csv-delim flag. Zero means "use the
+// format's own default": ',' for csv, a tab for tsv.
+var CSVDelimiter rune
+
+// DetectFormat infers a format identifier ("json", "ndjson", "jsonseq",
+// "yaml", "toml", "csv" or "tsv") from a file name's extension, defaulting
+// to "json" when the extension is unrecognized or absent (e.g. "<stdin>").
+func DetectFormat(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	case ".csv":
+		return "csv"
+	case ".tsv":
+		return "tsv"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	case ".jsonseq", ".json-seq":
+		return "jsonseq"
+	default:
+		return "json"
+	}
+}
+
+// sniffFormat upgrades a "json" guess (DetectFormat's fallback for an
+// unrecognized or absent extension) to "jsonseq" if the stream's first
+// non-whitespace byte is the RFC 7464 record separator (0x1E) - about the
+// only input-format distinction that can be made reliably from a single
+// peeked byte, since JSON Text Sequences have no conventional file
+// extension to detect by name. It's only consulted when format wasn't
+// pinned by -F or a recognized extension; an explicit "json" stays "json".
+// Returns a reader that still sees every byte of r, peeked ones included.
+func sniffFormat(format string, r io.Reader) (string, io.Reader) {
+	if format != "json" {
+		return format, r
+	}
+
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return format, br
+		}
+		switch b[0] {
+		case jsonSeqRS:
+			return "jsonseq", br
+		case ' ', '\t', '\n', '\r':
+			br.Discard(1)
+		default:
+			return format, br
+		}
+	}
+}
+
+// DecoderOptions configures format-specific decoding behavior.
+type DecoderOptions struct {
+	CSVHasHeader bool
+	CSVDelimiter rune
+}
+
+// NewDecoder returns a Decoder that reads one root Value at a time from r in
+// the given format ("json", "ndjson", "jsonseq", "yaml", "toml", "csv" or
+// "tsv"; "" means "json").
+func NewDecoder(format string, r io.Reader, opts DecoderOptions) (Decoder, error) {
+	switch format {
+	case "", "json":
+		jp := newJsonParser(r)
+		return &jp, nil
+	case "ndjson":
+		return newNdjsonDecoder(r), nil
+	case "jsonseq":
+		return newJsonSeqDecoder(r), nil
+	case "yaml":
+		return newYamlDecoder(r), nil
+	case "toml":
+		return newTomlDecoder(r), nil
+	case "csv", "tsv":
+		delim := opts.CSVDelimiter
+		if delim == 0 {
+			if format == "tsv" {
+				delim = '\t'
+			} else {
+				delim = ','
+			}
+		}
+		return newCsvDecoder(r, opts.CSVHasHeader, delim), nil
+	default:
+		return nil, fmt.Errorf("unknown input format %q, expected json, ndjson, jsonseq, yaml, toml, csv or tsv", format)
+	}
+}
+
+// ndjsonDecoder reads one JSON value per line, for files that separate
+// top-level values with newlines instead of nesting them in an array.
+type ndjsonDecoder struct {
+	scanner *bufio.Scanner
+}
+
+func newNdjsonDecoder(r io.Reader) *ndjsonDecoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	return &ndjsonDecoder{scanner}
+}
+
+func (d *ndjsonDecoder) Next() (Value, error) {
+	for d.scanner.Scan() {
+		line := strings.TrimSpace(d.scanner.Text())
+		if line == "" {
+			continue
+		}
+		jp := newJsonParser(strings.NewReader(line))
+		return jp.Next()
+	}
+	if err := d.scanner.Err(); err != nil {
+		return Value{}, err
+	}
+	return Value{}, io.EOF
+}
+
+// csvDecoder decodes an entire CSV/TSV file as a single array-of-rows Value,
+// the same way a whole JSON file is one array Value - unlike ndjson, where
+// each line is its own independent document (and so gets its own
+// BEGINFILE/ENDFILE pass), a table's rows all belong to one document and
+// should only run through BEGINFILE/ENDFILE once for the whole file.
+type csvDecoder struct {
+	reader *csv.Reader
+	header []string
+	done   bool
+}
+
+func newCsvDecoder(r io.Reader, hasHeader bool, delimiter rune) *csvDecoder {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.Comma = delimiter
+	d := &csvDecoder{reader: cr}
+	if hasHeader {
+		if header, err := cr.Read(); err == nil {
+			d.header = header
+		}
+	}
+	return d
+}
+
+func (d *csvDecoder) Next() (Value, error) {
+	if d.done {
+		return Value{}, io.EOF
+	}
+	d.done = true
+
+	rows := NewArray()
+	for {
+		record, err := d.reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Value{}, err
+		}
+		rows.Array = append(rows.Array, NewCell(buildCsvRow(record, d.header)))
+	}
+	return rows, nil
+}
@@ -1,27 +1,92 @@
 package lang
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 type SyntaxError struct {
 	Message string
+	// Pos and Fset are the source of truth for Line/Col/SrcLine below -
+	// they let PrintErrorTo resolve a file:line:col prefix (via
+	// spanFileName) without rescanning the source. Fset is nil for a SyntaxError built directly
+	// from literal Line/Col/SrcLine fields (e.g. in tests) rather than
+	// through newSyntaxError.
+	Pos     int
+	Fset    *FileSet
 	Line    int
 	Col     int
 	SrcLine string
+	EndLine int
+	EndCol  int
+	// UnexpectedEOF is set when this error means "the input ran out before
+	// the program was complete" as opposed to a genuine mistake that merely
+	// happens to be positioned at EOF (e.g. "can only break inside a loop"
+	// on the last line). A caller that can offer more input - the REPL -
+	// uses this to decide whether to prompt for a continuation line instead
+	// of reporting a failure.
+	UnexpectedEOF bool
 }
 
 func (err SyntaxError) Error() string {
 	return err.Message
 }
 
+// IsUnexpectedEOF reports whether err is a SyntaxError caused by the input
+// ending before the program was complete, as opposed to a genuine mistake.
+// An ErrorGroup counts only if every error it holds agrees - one real
+// mistake alongside an EOF complaint about the unterminated block it left
+// behind should still be reported, not swallowed into "needs more input".
+func IsUnexpectedEOF(err error) bool {
+	switch e := err.(type) {
+	case SyntaxError:
+		return e.UnexpectedEOF
+	case ErrorGroup:
+		if len(e.Errors) == 0 {
+			return false
+		}
+		for _, inner := range e.Errors {
+			if !IsUnexpectedEOF(inner) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// newSyntaxError resolves pos through fset once, via File.Position's O(log
+// n) binary search, instead of the per-call full-source rescan this used to
+// require.
+func newSyntaxError(fset *FileSet, pos int, msg string) SyntaxError {
+	_, line, col, srcLine := fset.Position(pos)
+	return SyntaxError{
+		Message: msg,
+		Pos:     pos,
+		Fset:    fset,
+		Line:    line,
+		Col:     col,
+		SrcLine: srcLine,
+		EndLine: line,
+		EndCol:  col + 1,
+	}
+}
+
 type RuntimeError struct {
 	Message string
+	Pos     int
+	Fset    *FileSet
 	Line    int
 	Col     int
 	SrcLine string
+	EndLine int
+	EndCol  int
 }
 
 func (err RuntimeError) Error() string {
@@ -52,9 +117,72 @@ func (err ErrorGroup) Error() string {
 	return sb.String()
 }
 
+// ErrorList accumulates the syntax errors found over a single Parse/
+// ParseExpression call, so a run with several unrelated mistakes reports
+// all of them instead of just the first. Parser.findNextStatement is what
+// lets parsing continue past one error to find the next.
+type ErrorList struct {
+	errors []error
+}
+
+// Add records err, ignoring a nil one so callers can pass a fallible
+// call's error straight through without an extra check.
+func (l *ErrorList) Add(err error) {
+	if err != nil {
+		l.errors = append(l.errors, err)
+	}
+}
+
+func (l *ErrorList) Len() int {
+	return len(l.errors)
+}
+
+// errorPos returns the (line, col) a SyntaxError/RuntimeError carries, or
+// (0, 0) for anything else (e.g. a nested ErrorGroup), which just sorts
+// that entry first rather than failing to sort at all.
+func errorPos(err error) (int, int) {
+	switch e := err.(type) {
+	case SyntaxError:
+		return e.Line, e.Col
+	case RuntimeError:
+		return e.Line, e.Col
+	default:
+		return 0, 0
+	}
+}
+
+// Sort orders the accumulated errors by (line, col) - recovery can find
+// errors out of source order (e.g. an unterminated block's closing-brace
+// complaint surfacing after a later statement's), and a reader expects a
+// batch of diagnostics listed top to bottom.
+func (l *ErrorList) Sort() {
+	sort.SliceStable(l.errors, func(i, j int) bool {
+		lineI, colI := errorPos(l.errors[i])
+		lineJ, colJ := errorPos(l.errors[j])
+		if lineI != lineJ {
+			return lineI < lineJ
+		}
+		return colI < colJ
+	})
+}
+
+// Err returns nil if nothing was added, the lone error directly if there's
+// only one, or a sorted ErrorGroup otherwise - the same error shapes
+// callers already handled before ErrorList existed.
+func (l *ErrorList) Err() error {
+	if len(l.errors) == 0 {
+		return nil
+	}
+	if len(l.errors) == 1 {
+		return l.errors[0]
+	}
+	l.Sort()
+	return ErrorGroup{l.errors}
+}
+
 func prefix(line string, col int) string {
 	var sb strings.Builder
-	for i := range col {
+	for i := 0; i < col; i++ {
 		if line[i] == '\t' {
 			sb.WriteRune('\t')
 		} else {
@@ -64,23 +192,144 @@ func prefix(line string, col int) string {
 	return sb.String()
 }
 
-func PrintError(err error) {
+// errorJSON is the wire shape for one diagnostic in PrintErrorJSON's output
+type errorJSON struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Col     int    `json:"col,omitempty"`
+	SrcLine string `json:"srcLine,omitempty"`
+	EndLine int    `json:"endLine,omitempty"`
+	EndCol  int    `json:"endCol,omitempty"`
+}
+
+func toErrorJSON(err error) errorJSON {
 	switch tErr := err.(type) {
 	case SyntaxError:
-		fmt.Fprintf(os.Stderr, "  %s\n", tErr.SrcLine)
-		fmt.Fprintf(os.Stderr, "  %s%s\n", prefix(tErr.SrcLine, tErr.Col), "^")
-		fmt.Fprintf(os.Stderr, "syntax error on line %d: %s\n", tErr.Line, tErr.Message)
+		return errorJSON{
+			Kind:    "syntax",
+			Message: tErr.Message,
+			File:    spanFileName(tErr.Fset, tErr.Pos),
+			Line:    tErr.Line,
+			Col:     tErr.Col,
+			SrcLine: tErr.SrcLine,
+			EndLine: tErr.EndLine,
+			EndCol:  tErr.EndCol,
+		}
 	case RuntimeError:
-		fmt.Fprintf(os.Stderr, "  %s\n", tErr.SrcLine)
-		fmt.Fprintf(os.Stderr, "  %s%s\n", prefix(tErr.SrcLine, tErr.Col), "^")
-		fmt.Fprintf(os.Stderr, "runtime error on line %d: %s\n", tErr.Line, tErr.Message)
+		return errorJSON{
+			Kind:    "runtime",
+			Message: tErr.Message,
+			File:    spanFileName(tErr.Fset, tErr.Pos),
+			Line:    tErr.Line,
+			Col:     tErr.Col,
+			SrcLine: tErr.SrcLine,
+			EndLine: tErr.EndLine,
+			EndCol:  tErr.EndCol,
+		}
+	case JsonError:
+		return errorJSON{
+			Kind:    "json",
+			Message: tErr.Message,
+			File:    tErr.FileName,
+		}
+	default:
+		return errorJSON{Kind: "error", Message: err.Error()}
+	}
+}
+
+// PrintErrorJSON writes err to w as a JSON object, one per diagnostic
+// (an array when err is an ErrorGroup), so editors, LSP wrappers, and CI
+// runners can consume jqawk's diagnostics without scraping the text format
+func PrintErrorJSON(w io.Writer, err error) error {
+	if group, ok := err.(ErrorGroup); ok {
+		items := make([]errorJSON, 0, len(group.Errors))
+		for _, e := range group.Errors {
+			items = append(items, toErrorJSON(e))
+		}
+		return json.NewEncoder(w).Encode(items)
+	}
+	return json.NewEncoder(w).Encode(toErrorJSON(err))
+}
+
+const (
+	ansiReset      = "\x1b[0m"
+	ansiRed        = "\x1b[31m"
+	ansiBoldWhite  = "\x1b[1;37m"
+	ansiDimGray    = "\x1b[2m"
+	ansiUnderlineC = "\x1b[1;36m"
+)
+
+// ErrorPrintOptions controls how PrintErrorTo renders a diagnostic.
+type ErrorPrintOptions struct {
+	Color bool // colorize the label, message, gutter and underline
+}
+
+// printSpanError renders one SyntaxError/RuntimeError as a gutter-prefixed
+// source line with a `~~~~` underline spanning [col, endCol), in the style
+// of rustc/elm diagnostics. fileName is only shown when non-empty, since a
+// single-program run (still the common case) has nothing worth naming.
+func printSpanError(w io.Writer, label, fileName string, line, col, endCol int, srcLine, message string, opts ErrorPrintOptions) {
+	gutter := strconv.Itoa(line)
+	pad := strings.Repeat(" ", len(gutter))
+
+	span := endCol - col
+	if span < 1 {
+		span = 1
+	}
+	underline := prefix(srcLine, col) + strings.Repeat("~", span)
+
+	dim, red, bold, bright, reset := "", "", "", "", ""
+	if opts.Color {
+		dim, red, bold, bright, reset = ansiDimGray, ansiRed, ansiBoldWhite, ansiUnderlineC, ansiReset
+	}
+
+	where := fmt.Sprintf("line %d", line)
+	if fileName != "" {
+		where = fmt.Sprintf("%s:%d:%d", fileName, line, col+1)
+	}
+
+	fmt.Fprintf(w, "%s%s |%s\n", dim, pad, reset)
+	fmt.Fprintf(w, "%s%s |%s %s\n", dim, gutter, reset, srcLine)
+	fmt.Fprintf(w, "%s%s |%s %s%s%s\n", dim, pad, reset, bright, underline, reset)
+	fmt.Fprintf(w, "%s%s on %s:%s %s%s%s\n", red, label, where, reset, bold, message, reset)
+}
+
+// spanFileName returns the originating file name for a SyntaxError/
+// RuntimeError's Pos, or "" if Fset is nil (built from literal fields
+// rather than newSyntaxError) or the position is still in the single
+// unnamed "<program>" source every CLI run starts with.
+func spanFileName(fset *FileSet, pos int) string {
+	if fset == nil {
+		return ""
+	}
+	f := fset.File(pos)
+	if f == nil || f.Name == "<program>" {
+		return ""
+	}
+	return f.Name
+}
+
+// PrintErrorTo renders err to w, underlining the full offending token span
+// and colorizing the output when opts.Color is set.
+func PrintErrorTo(w io.Writer, err error, opts ErrorPrintOptions) {
+	switch tErr := err.(type) {
+	case SyntaxError:
+		printSpanError(w, "syntax error", spanFileName(tErr.Fset, tErr.Pos), tErr.Line, tErr.Col, tErr.EndCol, tErr.SrcLine, tErr.Message, opts)
+	case RuntimeError:
+		printSpanError(w, "runtime error", spanFileName(tErr.Fset, tErr.Pos), tErr.Line, tErr.Col, tErr.EndCol, tErr.SrcLine, tErr.Message, opts)
 	case JsonError:
-		fmt.Fprintf(os.Stderr, "could not parse %s: %s\n", tErr.FileName, tErr.Message)
+		fmt.Fprintf(w, "could not parse %s: %s\n", tErr.FileName, tErr.Message)
 	case ErrorGroup:
 		for _, err := range tErr.Errors {
-			PrintError(err)
+			PrintErrorTo(w, err, opts)
 		}
 	default:
-		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(w, err)
 	}
 }
+
+func PrintError(err error) {
+	PrintErrorTo(os.Stderr, err, ErrorPrintOptions{})
+}
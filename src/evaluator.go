@@ -1,13 +1,16 @@
 package lang
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math/big"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 type stackFrame struct {
@@ -15,6 +18,12 @@ type stackFrame struct {
 	locals map[string]*Cell
 	depth  int
 	parent *stackFrame
+	// selfFn is the Cell a function's own frame was called through, so a
+	// `return` in tail position can tell a self-recursive call apart from a
+	// call to anything else - see the tail-call handling in callFunction and
+	// StatementReturn's case in evalStatement. Nil for every frame that
+	// isn't a function body (rule bodies, match arms, ...).
+	selfFn *Cell
 }
 
 type Evaluator struct {
@@ -31,30 +40,97 @@ type Evaluator struct {
 	endRules       []*Rule
 	endFileRules   []*Rule
 	fuzzing        bool
+	printFmt       printFormatter
+
+	// matchGroupNames tracks the $1, $2, ... locals currently bound by the
+	// last successful ~/!~ match, so they can be unbound once the rule that
+	// triggered them finishes running.
+	matchGroupNames []string
+	// namedMatchGroups holds (?P<name>...) captures from the last successful
+	// ~/!~ match, consulted by $["name"] before falling back to the record.
+	namedMatchGroups map[string]string
 }
 
 var (
-	errContinue = errors.New("continue")
-	errBreak    = errors.New("break")
-	errReturn   = errors.New("return")
-	errNext     = errors.New("next")
-	errExit     = errors.New("exit")
+	errReturn = errors.New("return")
+	errNext   = errors.New("next")
+	errExit   = errors.New("exit")
 )
 
+// loopSignal is what `break`/`continue` return to unwind toward an
+// enclosing loop. Label is empty for the unlabeled form, which the
+// innermost loop always consumes; a labeled one (`break outer`) keeps
+// propagating - each loop's case in evalStatement re-returns it unless its
+// own Label matches - until it reaches the loop it names.
+type loopSignal struct {
+	label   string
+	isBreak bool
+}
+
+func (s loopSignal) Error() string {
+	word := "continue"
+	if s.isBreak {
+		word = "break"
+	}
+	if s.label != "" {
+		return fmt.Sprintf("%s %s", word, s.label)
+	}
+	return word
+}
+
+// matches reports whether the loop labeled ownLabel is the one s should act
+// on: either s isn't targeting any particular loop, or it names this one.
+func (s loopSignal) matches(ownLabel string) bool {
+	return s.label == "" || s.label == ownLabel
+}
+
+// tailCallSignal unwinds a self-recursive `return f(...)` back to the
+// callFunction call that's running f, carrying the new argument values.
+// That call rebinds its own frame's locals and loops instead of recursing,
+// so self-recursive tree/array walks run in constant Go and call-depth-limit
+// stack space rather than one frame per recursive call.
+type tailCallSignal struct {
+	args []*Value
+}
+
+func (tailCallSignal) Error() string { return "tail call" }
+
 var fuzzingLoopLimit = 10000
 var callDepthLimit = 4096
 
-func NewEvaluator(prog Program, lexer *Lexer, stdout io.Writer) Evaluator {
+// RecursiveWalk is set by the CLI's -R flag. It switches evalPatternRules
+// from dispatching only against an array root's elements (or a non-array
+// root as one record) to walking the
+// entire tree in pre-order, dispatching at every descendant - see
+// evalPatternRulesRecursive.
+var RecursiveWalk bool
+
+// Jobs is set by the CLI's --jobs flag. The default, 1, keeps
+// EvalParsedProgram's per-record loop serial. A value above 1 batches up to
+// that many consecutive records together and runs each one's pattern rules
+// on its own cloned Evaluator, concurrently within the batch - see
+// runRecordBatch.
+var Jobs = 1
+
+func NewEvaluator(prog Program, lexer *Lexer, stdout io.Writer) (Evaluator, error) {
 	e := Evaluator{
-		prog:   prog,
-		lexer:  lexer,
-		stdout: stdout,
+		prog:             prog,
+		lexer:            lexer,
+		stdout:           stdout,
+		namedMatchGroups: make(map[string]string),
+		printFmt:         newPrintFormatter(stdout),
 	}
 	e.readRules()
 	e.pushFrame("<root>")
 	addRuntimeFunctions(&e)
 	e.addProgramFunctions()
-	return e
+	if err := registerHostFuncs(&e); err != nil {
+		return e, err
+	}
+	if err := e.resolveImports(); err != nil {
+		return e, err
+	}
+	return e, nil
 }
 
 func (e *Evaluator) readRules() {
@@ -102,13 +178,38 @@ func (e *Evaluator) print(str string) {
 	fmt.Fprint(e.stdout, str)
 }
 
+// printCellFor renders a value for `print`, flagging numbers so the table
+// formatter knows to right-align that column.
+func printCellFor(v *Value) PrintCell {
+	return PrintCell{
+		Text:     v.PrettyString(false),
+		IsNumber: v.Tag == ValueNum,
+	}
+}
+
 func (e *Evaluator) error(token Token, msg string) RuntimeError {
-	srcLine, line, col := e.lexer.GetLineAndCol(token.Pos)
+	_, line, col, srcLine := e.lexer.fset.Position(token.Pos)
 	return RuntimeError{
 		Message: msg,
+		Pos:     token.Pos,
+		Fset:    e.lexer.fset,
 		Line:    line,
 		Col:     col,
 		SrcLine: srcLine,
+		EndLine: line,
+		EndCol:  col + max(token.Len, 1),
+	}
+}
+
+// wrapError reports err at token's position, unless err already carries its
+// own source span (it came from somewhere deeper, e.g. a called function's
+// body), in which case that original position is kept.
+func (e *Evaluator) wrapError(token Token, err error) error {
+	switch err.(type) {
+	case RuntimeError, SyntaxError:
+		return err
+	default:
+		return e.error(token, err.Error())
 	}
 }
 
@@ -226,6 +327,13 @@ func (e *Evaluator) evalExpr(expr Expr) (*Cell, error) {
 			return cell, nil
 		case Regex:
 			str := e.lexer.GetString(&exp.token)
+			if len(exp.token.Flags) > 0 {
+				prefix, err := regexFlagsGroup(exp.token.Flags)
+				if err != nil {
+					return nil, e.error(expr.Token(), err.Error())
+				}
+				str = prefix + str
+			}
 			val := Value{
 				Tag: ValueRegex,
 				Str: &str,
@@ -251,26 +359,26 @@ func (e *Evaluator) evalExpr(expr Expr) (*Cell, error) {
 		return e.evalUnaryExpr(exp)
 	case *ExprBinary:
 		return e.evalBinaryExpr(exp)
-	case *ExprIdentifier:
-		return e.getIdentifier(exp)
-	case *ExprCall:
-		fn, err := e.evalExpr(exp.Func)
+	case *ExprTernary:
+		cond, err := e.evalExpr(exp.Cond)
 		if err != nil {
 			return nil, err
 		}
-
-		args, err := e.evalExprList(exp.Args, true)
+		if cond.Value.isTruthy() {
+			return e.evalExpr(exp.Then)
+		}
+		return e.evalExpr(exp.Else)
+	case *ExprIdentifier:
+		return e.getIdentifier(exp)
+	case *ExprCall:
+		fn, argVals, err := e.evalCallArgs(exp)
 		if err != nil {
 			return nil, err
 		}
-		argVals := make([]*Value, 0, len(args))
-		for _, argCell := range args {
-			argVals = append(argVals, &argCell.Value)
-		}
 
 		result, err := e.callFunction(fn, argVals)
 		if err != nil {
-			return nil, e.error(exp.Token(), err.Error())
+			return nil, e.wrapError(exp.Token(), err)
 		}
 		return result, nil
 	case *ExprArray:
@@ -304,6 +412,19 @@ func (e *Evaluator) evalExpr(expr Expr) (*Cell, error) {
 					e.stackTop.locals[k] = v
 				}
 
+				if matchCase.Guard != nil {
+					guardVal, err := e.evalExpr(matchCase.Guard)
+					if err != nil {
+						return nil, err
+					}
+					if !guardVal.Value.isTruthy() {
+						if err := e.popFrame(); err != nil {
+							return nil, err
+						}
+						continue
+					}
+				}
+
 				switch body := matchCase.Body.(type) {
 				case *StatementExpr:
 					val, err := e.evalExpr(body.Expr)
@@ -414,6 +535,9 @@ func (e *Evaluator) assignToTarget(target AssignTarget, value *Cell) (*Cell, Val
 			if err != nil {
 				return nil, Value{}, e.error(tok, err.Error())
 			}
+			if oldValue == nil {
+				return newVal, NewValue(nil), nil
+			}
 			return newVal, oldValue.Value, nil
 		}
 
@@ -437,6 +561,36 @@ func (e *Evaluator) assignToTarget(target AssignTarget, value *Cell) (*Cell, Val
 	return curr, oldValue, nil
 }
 
+// typeNameMatches reports whether v's runtime type matches the type name
+// identExpr refers to (e.g. `number`, `string`, or the `function`/`null`
+// keywords). It backs both `x is T` expressions and `x is T` match patterns.
+func (e *Evaluator) typeNameMatches(v *Value, identExpr *ExprIdentifier) bool {
+	switch identExpr.token.Tag {
+	case Function:
+		return v.Tag == ValueFn
+	case Null:
+		return v.Tag == ValueNil
+	}
+
+	switch e.lexer.GetString(&identExpr.token) {
+	case "string":
+		return v.Tag == ValueStr
+	case "bool":
+		return v.Tag == ValueBool
+	case "number":
+		return v.Tag == ValueNum
+	case "array":
+		return v.Tag == ValueArray
+	case "object":
+		return v.Tag == ValueObj
+	case "regex":
+		return v.Tag == ValueRegex
+	case "unknown":
+		return v.Tag == ValueUnknown
+	}
+	return false
+}
+
 func (e *Evaluator) evalCaseMatch(value *Cell, exprs []Expr) (bool, map[string]*Cell, error) {
 	for _, expr := range exprs {
 		switch ex := expr.(type) {
@@ -453,32 +607,44 @@ func (e *Evaluator) evalCaseMatch(value *Cell, exprs []Expr) (bool, map[string]*
 				return true, nil, nil
 			}
 		case *ExprArray:
-			if value.Value.Tag != ValueArray {
-				return false, nil, nil
+			match, bindings, err := e.evalArrayCaseMatch(value, ex)
+			if err != nil {
+				return false, nil, err
 			}
-
-			array := value.Value.Array
-			if len(array) != len(ex.Items) {
-				return false, nil, nil
+			if match {
+				return true, bindings, nil
 			}
-
-			bindings := make(map[string]*Cell)
-
-			for i, item := range array {
-				exprToMatch := ex.Items[i]
-				match, newBindings, err := e.evalCaseMatch(item, []Expr{exprToMatch})
-				if err != nil {
-					return false, nil, err
+		case *ExprObject:
+			match, bindings, err := e.evalObjectCaseMatch(value, ex)
+			if err != nil {
+				return false, nil, err
+			}
+			if match {
+				return true, bindings, nil
+			}
+		case *ExprBinary:
+			switch ex.OpToken.Tag {
+			case Is:
+				identExpr, ok := ex.Right.(*ExprIdentifier)
+				if !ok {
+					return false, nil, e.error(ex.Right.Token(), "expected a type name")
 				}
-				if !match {
+				if !e.typeNameMatches(&value.Value, identExpr) {
 					return false, nil, nil
 				}
-				for k, v := range newBindings {
-					bindings[k] = v
+				return e.evalCaseMatch(value, []Expr{ex.Left})
+			case Pipe:
+				match, bindings, err := e.evalCaseMatch(value, []Expr{ex.Left})
+				if err != nil {
+					return false, nil, err
 				}
+				if match {
+					return true, bindings, nil
+				}
+				return e.evalCaseMatch(value, []Expr{ex.Right})
+			default:
+				return false, nil, e.error(expr.Token(), fmt.Sprintf("%s not supported in match expressions", expr))
 			}
-
-			return true, bindings, nil
 		case *ExprIdentifier:
 			bindings := make(map[string]*Cell)
 			ident := e.lexer.GetString(&ex.token)
@@ -491,6 +657,138 @@ func (e *Evaluator) evalCaseMatch(value *Cell, exprs []Expr) (bool, map[string]*
 	return false, nil, nil
 }
 
+// evalArrayCaseMatch matches value against a fixed-length array pattern, or
+// against one with a "...tail" rest item capturing the leftovers.
+func (e *Evaluator) evalArrayCaseMatch(value *Cell, ex *ExprArray) (bool, map[string]*Cell, error) {
+	if value.Value.Tag != ValueArray {
+		return false, nil, nil
+	}
+	array := value.Value.Array
+
+	restIndex := -1
+	for i, item := range ex.Items {
+		if _, ok := item.(*ExprRestPattern); ok {
+			restIndex = i
+			break
+		}
+	}
+
+	if restIndex == -1 {
+		if len(array) != len(ex.Items) {
+			return false, nil, nil
+		}
+	} else if len(array) < len(ex.Items)-1 {
+		return false, nil, nil
+	}
+
+	bindings := make(map[string]*Cell)
+	matchItem := func(i int, itemExpr Expr) (bool, error) {
+		match, newBindings, err := e.evalCaseMatch(array[i], []Expr{itemExpr})
+		if err != nil {
+			return false, err
+		}
+		if !match {
+			return false, nil
+		}
+		for k, v := range newBindings {
+			bindings[k] = v
+		}
+		return true, nil
+	}
+
+	if restIndex == -1 {
+		for i := range array {
+			match, err := matchItem(i, ex.Items[i])
+			if err != nil {
+				return false, nil, err
+			}
+			if !match {
+				return false, nil, nil
+			}
+		}
+		return true, bindings, nil
+	}
+
+	for i := 0; i < restIndex; i++ {
+		match, err := matchItem(i, ex.Items[i])
+		if err != nil {
+			return false, nil, err
+		}
+		if !match {
+			return false, nil, nil
+		}
+	}
+
+	tailLen := len(ex.Items) - restIndex - 1
+	for j := 0; j < tailLen; j++ {
+		match, err := matchItem(len(array)-tailLen+j, ex.Items[restIndex+1+j])
+		if err != nil {
+			return false, nil, err
+		}
+		if !match {
+			return false, nil, nil
+		}
+	}
+
+	rest := NewArray()
+	rest.Array = append(rest.Array, array[restIndex:len(array)-tailLen]...)
+	bindings[ex.Items[restIndex].(*ExprRestPattern).Ident] = NewCell(rest)
+
+	return true, bindings, nil
+}
+
+// evalObjectCaseMatch matches value against an object pattern, which only
+// matches objects containing (at least) the named fields. A "...rest" item
+// captures the fields the pattern didn't name into an object.
+func (e *Evaluator) evalObjectCaseMatch(value *Cell, ex *ExprObject) (bool, map[string]*Cell, error) {
+	if value.Value.Tag != ValueObj {
+		return false, nil, nil
+	}
+
+	bindings := make(map[string]*Cell)
+	used := make(map[string]bool)
+	restIdent := ""
+
+	for _, kv := range ex.Items {
+		if rest, ok := kv.Value.(*ExprRestPattern); ok {
+			restIdent = rest.Ident
+			continue
+		}
+
+		field, present := (*value.Value.Obj)[kv.Key]
+		if !present {
+			return false, nil, nil
+		}
+		used[kv.Key] = true
+
+		match, newBindings, err := e.evalCaseMatch(field, []Expr{kv.Value})
+		if err != nil {
+			return false, nil, err
+		}
+		if !match {
+			return false, nil, nil
+		}
+		for k, v := range newBindings {
+			bindings[k] = v
+		}
+	}
+
+	if restIdent != "" {
+		rest := NewObject()
+		for _, key := range value.Value.ObjKeys {
+			if used[key] {
+				continue
+			}
+			cell := (*value.Value.Obj)[key]
+			(*rest.Obj)[key] = cell
+			rest.ObjKeys = append(rest.ObjKeys, key)
+		}
+		bindings[restIdent] = NewCell(rest)
+	}
+
+	return true, bindings, nil
+}
+
 func (e *Evaluator) swapStackTop(newStackTop *stackFrame) *stackFrame {
 	oldStackTop := e.stackTop
 
@@ -511,6 +809,26 @@ func (e *Evaluator) swapStackTop(newStackTop *stackFrame) *stackFrame {
 	return oldStackTop
 }
 
+// evalCallArgs evaluates a call's callee and arguments without invoking it,
+// so a caller can inspect the callee (e.g. StatementReturn, checking for a
+// self-tail-call) before deciding whether to call through callFunction.
+func (e *Evaluator) evalCallArgs(exp *ExprCall) (*Cell, []*Value, error) {
+	fn, err := e.evalExpr(exp.Func)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	args, err := e.evalExprList(exp.Args, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	argVals := make([]*Value, 0, len(args))
+	for _, argCell := range args {
+		argVals = append(argVals, &argCell.Value)
+	}
+	return fn, argVals, nil
+}
+
 func (e *Evaluator) callFunction(fn *Cell, args []*Value) (*Cell, error) {
 	switch fn.Value.Tag {
 	case ValueNativeFn:
@@ -531,26 +849,36 @@ func (e *Evaluator) callFunction(fn *Cell, args []*Value) (*Cell, error) {
 		if err := e.pushFrame(name); err != nil {
 			return nil, err
 		}
+		e.stackTop.selfFn = fn
 
-		e.stackTop.locals[name] = fn
-
-		for index, argName := range f.Expr.Args {
-			if index > len(args)-1 {
-				e.stackTop.locals[argName] = NewCell(NewValue(nil))
-			} else {
-				e.stackTop.locals[argName] = NewCell(*args[index])
+		bindArgs := func(args []*Value) {
+			e.stackTop.locals = make(map[string]*Cell)
+			e.stackTop.locals[name] = fn
+			for index, argName := range f.Expr.Args {
+				if index > len(args)-1 {
+					e.stackTop.locals[argName] = NewCell(NewValue(nil))
+				} else {
+					e.stackTop.locals[argName] = NewCell(*args[index])
+				}
 			}
 		}
-
-		err := e.evalStatement(f.Expr.Body)
+		bindArgs(args)
 
 		var retVal *Value
-		if err == errReturn {
-			retVal = e.returnVal
-		} else if err != nil {
-			return nil, err
-		} else {
-			retVal = nil
+		for {
+			err := e.evalStatement(f.Expr.Body)
+			if tc, ok := err.(tailCallSignal); ok {
+				bindArgs(tc.args)
+				continue
+			}
+			if err == errReturn {
+				retVal = e.returnVal
+			} else if err != nil {
+				return nil, err
+			} else {
+				retVal = nil
+			}
+			break
 		}
 
 		if err := e.popFrame(); err != nil {
@@ -567,6 +895,24 @@ func (e *Evaluator) callFunction(fn *Cell, args []*Value) (*Cell, error) {
 	}
 }
 
+// CallFunction invokes fn (a ValueFn or ValueNativeFn) with args, the same
+// way an ExprCall does through evalCallArgs/callFunction. It's the re-entry
+// point a native function needs to call back into a user-supplied callback
+// - the array prototype's map/filter/reduce/find/some/every/sort use this
+// to invoke the function argument they're given against each element. this
+// is what a NativeFn callback sees as its own third argument; it plays no
+// part in calling a ValueFn, since jqawk functions don't have a `this`.
+func (e *Evaluator) CallFunction(fn *Value, args []*Value, this *Value) (*Value, error) {
+	cell := NewCell(*fn)
+	cell.Value.Binding = this
+
+	result, err := e.callFunction(cell, args)
+	if err != nil {
+		return nil, err
+	}
+	return &result.Value, nil
+}
+
 func (e *Evaluator) evalUnaryExpr(expr *ExprUnary) (*Cell, error) {
 	val, err := e.evalExpr(expr.Expr)
 	if err != nil {
@@ -608,6 +954,20 @@ func (e *Evaluator) evalUnaryExpr(expr *ExprUnary) (*Cell, error) {
 	}
 }
 
+// regexFlagsGroup turns a regex literal's trailing flags (e.g. "im" from
+// /foo/im) into a Go regexp inline flag group ("(?im)"), so it can be
+// prepended to the pattern before anything calls regexp.Compile on it.
+func regexFlagsGroup(flags string) (string, error) {
+	for _, f := range flags {
+		switch f {
+		case 'i', 'm', 's':
+		default:
+			return "", fmt.Errorf("unknown regex flag %q", string(f))
+		}
+	}
+	return "(?" + flags + ")", nil
+}
+
 func (e *Evaluator) evalBinaryExpr(expr *ExprBinary) (*Cell, error) {
 	left, err := e.evalExpr(expr.Left)
 	if err != nil {
@@ -648,35 +1008,7 @@ func (e *Evaluator) evalBinaryExpr(expr *ExprBinary) (*Cell, error) {
 	if expr.OpToken.Tag == Is {
 		switch exp := expr.Right.(type) {
 		case *ExprIdentifier:
-			result := false
-
-			switch exp.token.Tag {
-			case Function:
-				result = left.Value.Tag == ValueFn
-				return NewCell(NewValue(result)), nil
-			case Null:
-				result = left.Value.Tag == ValueNil
-				return NewCell(NewValue(result)), nil
-			}
-
-			s := e.lexer.GetString(&exp.token)
-			switch s {
-			case "string":
-				result = left.Value.Tag == ValueStr
-			case "bool":
-				result = left.Value.Tag == ValueBool
-			case "number":
-				result = left.Value.Tag == ValueNum
-			case "array":
-				result = left.Value.Tag == ValueArray
-			case "object":
-				result = left.Value.Tag == ValueObj
-			case "regex":
-				result = left.Value.Tag == ValueRegex
-			case "unknown":
-				result = left.Value.Tag == ValueUnknown
-			}
-			return NewCell(NewValue(result)), nil
+			return NewCell(NewValue(e.typeNameMatches(&left.Value, exp))), nil
 		}
 
 		return nil, e.error(expr.Right.Token(), "expected a type name")
@@ -752,6 +1084,14 @@ func (e *Evaluator) evalBinaryExpr(expr *ExprBinary) (*Cell, error) {
 
 	switch expr.OpToken.Tag {
 	case LSquare, Dot:
+		if expr.OpToken.Tag == LSquare && right.Value.Tag == ValueStr {
+			if ident, ok := expr.Left.(*ExprIdentifier); ok && ident.token.Tag == Dollar {
+				if captured, ok := e.namedMatchGroups[*right.Value.Str]; ok {
+					return NewCell(NewString(captured)), nil
+				}
+			}
+		}
+
 		if left.Value.Tag == ValueUnknown {
 			if right.Value.Tag == ValueNum {
 				// if it's unknown and the rhs is a number, make it an array
@@ -813,6 +1153,25 @@ func (e *Evaluator) evalBinaryExpr(expr *ExprBinary) (*Cell, error) {
 			return NewCell(NewValue(leftStr + rightStr)), nil
 		}
 
+		if left.Value.Tag == ValueNum && right.Value.Tag == ValueNum &&
+			left.Value.BigInt != nil && right.Value.BigInt != nil {
+			switch expr.OpToken.Tag {
+			case Plus:
+				return NewCell(newBigIntResult(new(big.Int).Add(left.Value.BigInt, right.Value.BigInt))), nil
+			case Minus:
+				return NewCell(newBigIntResult(new(big.Int).Sub(left.Value.BigInt, right.Value.BigInt))), nil
+			case Multiply:
+				return NewCell(newBigIntResult(new(big.Int).Mul(left.Value.BigInt, right.Value.BigInt))), nil
+			case Percent:
+				if right.Value.BigInt.Sign() == 0 {
+					return nil, e.error(expr.OpToken, "divide by zero")
+				}
+				return NewCell(newBigIntResult(new(big.Int).Rem(left.Value.BigInt, right.Value.BigInt))), nil
+			}
+			// Divide falls through to the float64 path below: exact big
+			// integer division isn't implemented, only +, - , * and %.
+		}
+
 		leftNum := left.Value.asFloat64()
 		rightNum := right.Value.asFloat64()
 		switch expr.OpToken.Tag {
@@ -862,11 +1221,15 @@ func (e *Evaluator) evalBinaryExpr(expr *ExprBinary) (*Cell, error) {
 			return nil, e.error(expr.Right.Token(), err.Error())
 		}
 
+		submatches := re.FindStringSubmatch(str)
+
 		var v Value
-		if re.MatchString(str) {
+		if submatches != nil {
 			v = NewValue(true)
+			e.setMatchGroups(re, submatches)
 		} else {
 			v = NewValue(false)
+			e.clearMatchGroups()
 		}
 
 		if expr.OpToken.Tag == BangTilde {
@@ -878,15 +1241,23 @@ func (e *Evaluator) evalBinaryExpr(expr *ExprBinary) (*Cell, error) {
 	}
 }
 
+// newBigIntResult wraps the exact result of a big.Int arithmetic operation
+// back into a Value, alongside its nearest float64 approximation.
+func newBigIntResult(i *big.Int) Value {
+	f, _ := new(big.Float).SetInt(i).Float64()
+	return NewBigIntValue(i, f)
+}
+
 func copyValue(from *Cell, to *Cell) (*Cell, error) {
 	switch from.Value.Tag {
 	// copy
 	case ValueNum:
 		n := *from.Value.Num
 		to.Value = Value{
-			Tag:   ValueNum,
-			Num:   &n,
-			Proto: from.Value.Proto,
+			Tag:    ValueNum,
+			Num:    &n,
+			BigInt: from.Value.BigInt,
+			Proto:  from.Value.Proto,
 		}
 	case ValueBool:
 		b := *from.Value.Bool
@@ -957,21 +1328,33 @@ func (e *Evaluator) evalStatement(stmt Statement) error {
 		}
 
 		if len(args) == 0 {
-			fmt.Fprintln(e.stdout, e.ruleRoot.Value.PrettyString(false))
+			// whole-record print: the table formatter breaks an object
+			// record into one column per field so it can align them and
+			// derive a header from its keys; every other formatter (and
+			// every non-object record) prints the whole record as one cell.
+			if _, ok := e.printFmt.(*tablePrintFormatter); ok && e.ruleRoot.Value.Tag == ValueObj {
+				header := e.ruleRoot.Value.ObjKeys
+				cols := make([]PrintCell, len(header))
+				for i, key := range header {
+					member := (*e.ruleRoot.Value.Obj)[key]
+					cols[i] = printCellFor(&member.Value)
+				}
+				e.printFmt.row(header, cols)
+			} else {
+				e.printFmt.row(nil, []PrintCell{printCellFor(&e.ruleRoot.Value)})
+			}
 			return nil
 		}
 
+		cols := make([]PrintCell, len(args))
 		for i, cell := range args {
-			if i > 0 {
-				fmt.Fprint(e.stdout, " ")
-			}
 			if cell == nil {
-				fmt.Fprint(e.stdout, "null")
+				cols[i] = PrintCell{Text: "null"}
 			} else {
-				fmt.Fprintf(e.stdout, "%s", cell.Value.PrettyString(false))
+				cols[i] = printCellFor(&cell.Value)
 			}
 		}
-		fmt.Fprint(e.stdout, "\n")
+		e.printFmt.row(nil, cols)
 	case *StatementExpr:
 		_, err := e.evalExpr(st.Expr)
 		if err != nil {
@@ -979,15 +1362,32 @@ func (e *Evaluator) evalStatement(stmt Statement) error {
 		}
 		return nil
 	case *StatementReturn:
-		if st.Expr != nil {
-			cell, err := e.evalExpr(st.Expr)
+		if st.Expr == nil {
+			e.returnVal = nil
+			return errReturn
+		}
+
+		if call, ok := st.Expr.(*ExprCall); ok {
+			fn, argVals, err := e.evalCallArgs(call)
 			if err != nil {
 				return err
 			}
-			e.returnVal = &cell.Value
-		} else {
-			e.returnVal = nil
+			if e.stackTop.selfFn != nil && fn == e.stackTop.selfFn {
+				return tailCallSignal{argVals}
+			}
+			result, err := e.callFunction(fn, argVals)
+			if err != nil {
+				return e.wrapError(call.Token(), err)
+			}
+			e.returnVal = &result.Value
+			return errReturn
 		}
+
+		cell, err := e.evalExpr(st.Expr)
+		if err != nil {
+			return err
+		}
+		e.returnVal = &cell.Value
 		return errReturn
 	case *StatementIf:
 		cell, err := e.evalExpr(st.Expr)
@@ -1008,15 +1408,50 @@ func (e *Evaluator) evalStatement(stmt Statement) error {
 			}
 			if cell.Value.isTruthy() {
 				err := e.evalStatement(st.Body)
-				if err == errBreak {
-					break
-				} else if err != nil && err != errContinue {
+				if ls, ok := err.(loopSignal); ok {
+					if !ls.matches(st.Label) {
+						return ls
+					}
+					if ls.isBreak {
+						break
+					}
+				} else if err != nil {
 					return err
 				}
 			} else {
 				break
 			}
 
+			if e.fuzzing {
+				if loopCount > fuzzingLoopLimit {
+					return e.error(st.Token(), "fuzz test loop limit")
+				}
+			}
+			loopCount++
+		}
+	case *StatementDoWhile:
+		loopCount := 0
+		for {
+			err := e.evalStatement(st.Body)
+			if ls, ok := err.(loopSignal); ok {
+				if !ls.matches(st.Label) {
+					return ls
+				}
+				if ls.isBreak {
+					break
+				}
+			} else if err != nil {
+				return err
+			}
+
+			cell, err := e.evalExpr(st.Expr)
+			if err != nil {
+				return err
+			}
+			if !cell.Value.isTruthy() {
+				break
+			}
+
 			if e.fuzzing {
 				if loopCount > fuzzingLoopLimit {
 					return e.error(st.Token(), "fuzz test loop limit")
@@ -1034,9 +1469,14 @@ func (e *Evaluator) evalStatement(stmt Statement) error {
 			}
 			if cell.Value.isTruthy() {
 				err := e.evalStatement(st.Body)
-				if err == errBreak {
-					break
-				} else if err != nil && err != errContinue {
+				if ls, ok := err.(loopSignal); ok {
+					if !ls.matches(st.Label) {
+						return ls
+					}
+					if ls.isBreak {
+						break
+					}
+				} else if err != nil {
 					return err
 				}
 
@@ -1084,9 +1524,14 @@ func (e *Evaluator) evalStatement(stmt Statement) error {
 				}
 				local.Value = item.Value
 				err := e.evalStatement(st.Body)
-				if err == errBreak {
-					break
-				} else if err != nil && err != errContinue {
+				if ls, ok := err.(loopSignal); ok {
+					if !ls.matches(st.Label) {
+						return ls
+					}
+					if ls.isBreak {
+						break
+					}
+				} else if err != nil {
 					return err
 				}
 			}
@@ -1097,9 +1542,14 @@ func (e *Evaluator) evalStatement(stmt Statement) error {
 				}
 				local.Value = NewValue(k)
 				err := e.evalStatement(st.Body)
-				if err == errBreak {
-					break
-				} else if err != nil && err != errContinue {
+				if ls, ok := err.(loopSignal); ok {
+					if !ls.matches(st.Label) {
+						return ls
+					}
+					if ls.isBreak {
+						break
+					}
+				} else if err != nil {
 					return err
 				}
 			}
@@ -1110,9 +1560,14 @@ func (e *Evaluator) evalStatement(stmt Statement) error {
 				}
 				local.Value = NewString(string(c))
 				err := e.evalStatement(st.Body)
-				if err == errBreak {
-					break
-				} else if err != nil && err != errContinue {
+				if ls, ok := err.(loopSignal); ok {
+					if !ls.matches(st.Label) {
+						return ls
+					}
+					if ls.isBreak {
+						break
+					}
+				} else if err != nil {
 					return err
 				}
 			}
@@ -1120,9 +1575,9 @@ func (e *Evaluator) evalStatement(stmt Statement) error {
 			return e.error(st.Iterable.Token(), fmt.Sprintf("%s is not iterable", iterable.Value.Tag))
 		}
 	case *StatementBreak:
-		return errBreak
+		return loopSignal{label: st.Label, isBreak: true}
 	case *StatementContinue:
-		return errContinue
+		return loopSignal{label: st.Label, isBreak: false}
 	case *StatementNext:
 		return errNext
 	case *StatementExit:
@@ -1133,6 +1588,36 @@ func (e *Evaluator) evalStatement(stmt Statement) error {
 	return nil
 }
 
+// setMatchGroups binds $0 (the full match), $1, $2, ... (submatches) and any
+// named (?P<name>...) captures from a successful ~/!~ match so the current
+// rule's action can read them.
+func (e *Evaluator) setMatchGroups(re *regexp.Regexp, submatches []string) {
+	e.clearMatchGroups()
+
+	names := re.SubexpNames()
+	for i := 0; i < len(submatches); i++ {
+		name := fmt.Sprintf("$%d", i)
+		e.stackTop.locals[name] = NewCell(NewString(submatches[i]))
+		e.matchGroupNames = append(e.matchGroupNames, name)
+		if i < len(names) && names[i] != "" {
+			e.namedMatchGroups[names[i]] = submatches[i]
+		}
+	}
+}
+
+// clearMatchGroups unbinds whatever the last ~/!~ match bound, so a rule
+// with no match (or BEGIN/END, which never call this at all) doesn't see
+// stale capture groups from an earlier record.
+func (e *Evaluator) clearMatchGroups() {
+	for _, name := range e.matchGroupNames {
+		delete(e.stackTop.locals, name)
+	}
+	e.matchGroupNames = e.matchGroupNames[:0]
+	for name := range e.namedMatchGroups {
+		delete(e.namedMatchGroups, name)
+	}
+}
+
 func (e *Evaluator) evalRules(rules []*Rule) error {
 	for _, rule := range rules {
 		match := true
@@ -1145,10 +1630,12 @@ func (e *Evaluator) evalRules(rules []*Rule) error {
 		}
 
 		if !match {
+			e.clearMatchGroups()
 			continue
 		}
 
 		err := e.evalStatement(rule.Body)
+		e.clearMatchGroups()
 		if err == errNext {
 			return nil
 		}
@@ -1164,6 +1651,10 @@ func (e *Evaluator) evalPatternRules(patternRules []*Rule) error {
 		return nil
 	}
 
+	if RecursiveWalk {
+		return e.evalPatternRulesRecursive(patternRules)
+	}
+
 	switch e.root.Value.Tag {
 	case ValueArray:
 		for i, item := range e.root.Value.Array {
@@ -1173,18 +1664,45 @@ func (e *Evaluator) evalPatternRules(patternRules []*Rule) error {
 				return err
 			}
 		}
+	default:
+		// Objects, like every other non-array value, are dispatched as a
+		// single whole record - a matched (-P) or top-level JSON object is
+		// one document, not one record per field. Per-field iteration is
+		// only available to a rule body explicitly, via `for (x in $)`.
+		e.ruleRoot = e.root
+		if err := e.evalRules(patternRules); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// evalPatternRulesRecursive is evalPatternRules' -R counterpart: instead of
+// dispatching rules against an array root's elements (or a non-array root
+// as one record), it walks the whole tree in pre-order, dispatching at every
+// descendant. $key/$index carry the visited node's immediate parent
+// key/index (the flat mode sets only $index, for an array root's elements);
+// $path additionally carries the full chain of keys/indices from the
+// root down to the visited node, so a rule body can tell where in the tree
+// it's firing.
+func (e *Evaluator) evalPatternRulesRecursive(patternRules []*Rule) error {
+	switch e.root.Value.Tag {
+	case ValueArray:
+		for i, item := range e.root.Value.Array {
+			if err := e.walkPatternRules(item, []interface{}{i}, patternRules); err != nil {
+				return err
+			}
+		}
 	case ValueObj:
 		for _, key := range e.root.Value.ObjKeys {
 			val := (*e.root.Value.Obj)[key]
-			e.ruleRoot = val
-			e.stackTop.locals["$key"] = NewCell(NewValue(key))
-			if err := e.evalRules(patternRules); err != nil {
+			if err := e.walkPatternRules(val, []interface{}{key}, patternRules); err != nil {
 				return err
 			}
 		}
 	default:
-		e.ruleRoot = e.root
-		if err := e.evalRules(patternRules); err != nil {
+		if err := e.walkPatternRules(e.root, []interface{}{}, patternRules); err != nil {
 			return err
 		}
 	}
@@ -1192,6 +1710,58 @@ func (e *Evaluator) evalPatternRules(patternRules []*Rule) error {
 	return nil
 }
 
+// walkPatternRules runs patternRules against cell, then recurses into every
+// child cell has, depth-first and in source order - the same pre-order a
+// reader scanning the document top to bottom would visit it in. path is the
+// chain of keys/indices from the document root down to cell; it's reused as
+// the backing array across sibling calls (each sibling's last element
+// overwrites the previous one's), which is safe here because the walk is
+// single-threaded and every call finishes with it before the next sibling
+// starts.
+//
+// errNext is handled by evalRules itself (it stops that node's rules and
+// returns nil), so the walk just moves on to cell's children and then its
+// siblings. errExit, and any other error, unwinds the whole walk the same
+// way it already unwinds evalPatternRules' flat iteration. There's no
+// walk-wide analogue of break - break/continue are parsed only inside an
+// actual for/while loop (see loopSignal), and a pattern rule body run by the
+// walk isn't one, so there's nothing here for an unlabeled break to unwind
+// to.
+func (e *Evaluator) walkPatternRules(cell *Cell, path []interface{}, patternRules []*Rule) error {
+	e.ruleRoot = cell
+	e.stackTop.locals["$path"] = NewCell(NewValue(path))
+	if len(path) > 0 {
+		switch key := path[len(path)-1].(type) {
+		case int:
+			e.stackTop.locals["$index"] = NewCell(NewValue(key))
+		case string:
+			e.stackTop.locals["$key"] = NewCell(NewValue(key))
+		}
+	}
+
+	if err := e.evalRules(patternRules); err != nil {
+		return err
+	}
+
+	switch cell.Value.Tag {
+	case ValueArray:
+		for i, item := range cell.Value.Array {
+			if err := e.walkPatternRules(item, append(path, i), patternRules); err != nil {
+				return err
+			}
+		}
+	case ValueObj:
+		for _, key := range cell.Value.ObjKeys {
+			val := (*cell.Value.Obj)[key]
+			if err := e.walkPatternRules(val, append(path, key), patternRules); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 func (e *Evaluator) GetRootJson() (string, error) {
 	bytes, err := json.MarshalIndent(&e.root.Value, "", "  ")
 	if err != nil {
@@ -1200,6 +1770,78 @@ func (e *Evaluator) GetRootJson() (string, error) {
 	return string(bytes), nil
 }
 
+// GetRootJsonFormat renders the root value using the same output presets
+// json() understands ("pretty", "compact", "jsonl"), for the -o/-of CLI
+// flag combination. "jsonl" requires the root value to be an array.
+func (e *Evaluator) GetRootJsonFormat(format string) (string, error) {
+	if format == "jsonl" {
+		format = "ndjson"
+	}
+	presetVal := NewValue(format)
+	opts, err := parseJsonOutputOptions(&presetVal)
+	if err != nil {
+		return "", err
+	}
+	return encodeJson(&e.root.Value, opts)
+}
+
+// GetRootFormat renders the root value in the given output encoding
+// ("json", "yaml", "toml", "csv" or "ndjson"; "" means "json"), applying the
+// -of preset (jsonFormat) only when the encoding is JSON-based.
+func (e *Evaluator) GetRootFormat(encodeFormat string, jsonFormat string) (string, error) {
+	switch encodeFormat {
+	case "", "json":
+		return e.GetRootJsonFormat(jsonFormat)
+	case "ndjson":
+		return e.GetRootJsonFormat("ndjson")
+	case "yaml":
+		return encodeYAML(&e.root.Value)
+	case "toml":
+		return encodeTOML(&e.root.Value)
+	case "csv":
+		return encodeCSV(&e.root.Value)
+	default:
+		return "", fmt.Errorf("unknown output format %q, expected json, yaml, toml, csv or ndjson", encodeFormat)
+	}
+}
+
+// WriteRootFormat streams the root value to w in the same encodings
+// GetRootFormat understands. For "json"/"ndjson" (including "" and the
+// "jsonl" -of alias) this writes straight through Value.Encode instead of
+// building the whole document as a string first, so large -o outputs no
+// longer need to fit in memory twice over; yaml/toml/csv still go through
+// their string-returning encoders, since those aren't built to stream.
+func (e *Evaluator) WriteRootFormat(w io.Writer, encodeFormat string, jsonFormat string) error {
+	format := jsonFormat
+	switch encodeFormat {
+	case "ndjson":
+		format = "ndjson"
+		fallthrough
+	case "", "json":
+		if format == "jsonl" {
+			format = "ndjson"
+		}
+		presetVal := NewValue(format)
+		opts, err := parseJsonOutputOptions(&presetVal)
+		if err != nil {
+			return err
+		}
+		if err := e.root.Value.Encode(w, opts.toEncodeOptions()); err != nil {
+			return jsonMarshalError(err)
+		}
+		return nil
+	case "yaml", "toml", "csv":
+		s, err := e.GetRootFormat(encodeFormat, jsonFormat)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, s)
+		return err
+	default:
+		return fmt.Errorf("unknown output format %q, expected json, yaml, toml, csv or ndjson", encodeFormat)
+	}
+}
+
 func EvalExpression(exprSrc string, rootValue Value, stdout io.Writer) (*Cell, error) {
 	lex := NewLexer(exprSrc)
 	parser := NewParser(&lex)
@@ -1208,7 +1850,10 @@ func EvalExpression(exprSrc string, rootValue Value, stdout io.Writer) (*Cell, e
 		return nil, err
 	}
 	rootCell := NewCell(rootValue)
-	ev := NewEvaluator(Program{}, &lex, stdout)
+	ev, err := NewEvaluator(Program{}, &lex, stdout)
+	if err != nil {
+		return nil, err
+	}
 	ev.root = rootCell
 	ev.ruleRoot = rootCell
 	cell, err := ev.evalExpr(expr)
@@ -1218,15 +1863,215 @@ func EvalExpression(exprSrc string, rootValue Value, stdout io.Writer) (*Cell, e
 	return cell, nil
 }
 
-func EvalProgram(progSrc string, files []InputFile, rootSelectors []string, stdout io.Writer, fuzzing bool) (*Evaluator, error) {
-	lex := NewLexer(progSrc)
-	parser := NewParser(&lex)
-	prog, err := parser.Parse()
+// loadInlineProgram parses progSrc - a program given directly as a string
+// rather than read from a file - resolving any top-level `include`
+// directives it contains relative to the working directory, the same way
+// a shell would resolve a relative path typed at the prompt.
+func loadInlineProgram(progSrc string) (Program, *Lexer, error) {
+	loader := NewProgramLoader(nil)
+	return loader.LoadSource("<program>", progSrc, ".")
+}
+
+// EvalProgramStream behaves like EvalProgram, but reads a single file through
+// a StreamDecoder so large top-level arrays are processed one record at a
+// time instead of being fully materialized in memory first
+func EvalProgramStream(progSrc string, file InputFile, selector string, stdout io.Writer) (*Evaluator, error) {
+	prog, lex, err := loadInlineProgram(progSrc)
+	if err != nil {
+		return nil, err
+	}
+	ev, err := NewEvaluator(prog, lex, stdout)
+	if err != nil {
+		return &ev, err
+	}
+	defer func() { ev.printFmt.finish() }()
+
+	for _, rule := range ev.beginRules {
+		ev.ruleRoot = NewCell(NewValue(nil))
+		if err := ev.evalStatement(rule.Body); err != nil {
+			if err == errExit {
+				return &ev, nil
+			}
+			return &ev, err
+		}
+	}
+
+	sd, err := NewStreamDecoder(file.NewReader(), selector)
+	if err != nil {
+		return &ev, JsonError{err.Error(), file.Name()}
+	}
+
+	ev.setGlobal("$file", NewCell(NewValue(file.Name())))
+
+	for _, rule := range ev.beginFileRules {
+		ev.ruleRoot = NewCell(NewValue(nil))
+		if err := ev.evalStatement(rule.Body); err != nil {
+			if err == errExit {
+				return &ev, nil
+			}
+			return &ev, err
+		}
+	}
+
+	index := 0
+	for {
+		val, err := sd.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return &ev, JsonError{err.Error(), file.Name()}
+		}
+
+		cell := NewCell(val)
+		ev.root = cell
+		ev.ruleRoot = cell
+		ev.stackTop.locals["$index"] = NewCell(NewValue(index))
+		if err := ev.evalRules(ev.patternRules); err != nil {
+			if err == errExit {
+				return &ev, nil
+			}
+			return &ev, err
+		}
+		index++
+	}
+
+	for _, rule := range ev.endFileRules {
+		ev.ruleRoot = NewCell(NewValue(nil))
+		if err := ev.evalStatement(rule.Body); err != nil {
+			if err == errExit {
+				return &ev, nil
+			}
+			return &ev, err
+		}
+	}
+
+	for _, rule := range ev.endRules {
+		ev.ruleRoot = NewCell(NewValue(nil))
+		if err := ev.evalStatement(rule.Body); err != nil {
+			if err == errExit {
+				return &ev, nil
+			}
+			return &ev, err
+		}
+	}
+
+	return &ev, nil
+}
+
+// recordTask is one --jobs > 1 work item: a decoded record's root cell,
+// paired with the value it held going in (the same rootVal the serial path
+// captures) so ENDFILE still has something to run against once the batch
+// this record belongs to has finished.
+type recordTask struct {
+	rootCell *Cell
+	rootVal  Value
+}
+
+// runRecordBatch runs a batch of records' pattern rules concurrently, one
+// cloned Evaluator per record (see cloneForWorker), then - back on e, in
+// submission order - copies each worker's buffered stdout and runs that
+// record's ENDFILE rules. Flushing in submission order keeps output
+// deterministic regardless of which worker happens to finish first.
+//
+// Each clone's globals start as a fresh copy of e's globals at the time the
+// batch was built, so --jobs is only sound for pattern rule bodies that
+// treat already-existing globals as read-only: a worker's writes to one
+// aren't merged back into e, into ENDFILE, or into the other workers in the
+// same batch. New variables a rule body creates stay local to that worker,
+// so per-record scratch state is safe by construction. Programs that
+// accumulate into a global across records (a running sum, a seen-before
+// set) need the default --jobs=1, fully serial, mode instead.
+func (e *Evaluator) runRecordBatch(batch []recordTask, stdout io.Writer) error {
+	buffers := make([]bytes.Buffer, len(batch))
+	errs := make([]error, len(batch))
+
+	var wg sync.WaitGroup
+	for i, task := range batch {
+		wg.Add(1)
+		go func(i int, task recordTask) {
+			defer wg.Done()
+			worker := e.cloneForWorker(&buffers[i])
+			worker.root = task.rootCell
+			errs[i] = worker.evalPatternRules(worker.patternRules)
+			worker.printFmt.finish()
+		}(i, task)
+	}
+	wg.Wait()
+
+	for i, task := range batch {
+		if _, err := io.Copy(stdout, &buffers[i]); err != nil {
+			return err
+		}
+		if errs[i] != nil && errs[i] != errExit {
+			return errs[i]
+		}
+
+		for _, rule := range e.endFileRules {
+			e.ruleRoot = NewCell(task.rootVal)
+			if err := e.evalStatement(rule.Body); err != nil {
+				return err
+			}
+		}
+
+		if errs[i] == errExit {
+			return errExit
+		}
+	}
+
+	return nil
+}
+
+// cloneForWorker builds an isolated Evaluator for runRecordBatch: the same
+// compiled program and rule lists as e (read-only, safe to share across
+// goroutines), but its own root stack frame seeded with a fresh copy of e's
+// current globals and its own stdout, so concurrent workers can't race on
+// the same Cells or interleave output. See runRecordBatch's doc comment for
+// exactly what that isolation does and doesn't guarantee.
+func (e *Evaluator) cloneForWorker(stdout io.Writer) *Evaluator {
+	root := &stackFrame{
+		name:   "<root>",
+		locals: make(map[string]*Cell, len(e.stackTop.locals)),
+	}
+	for name, cell := range e.stackTop.locals {
+		root.locals[name] = NewCell(cell.Value)
+	}
+
+	return &Evaluator{
+		prog:             e.prog,
+		lexer:            e.lexer,
+		stdout:           stdout,
+		stackTop:         root,
+		beginRules:       e.beginRules,
+		beginFileRules:   e.beginFileRules,
+		patternRules:     e.patternRules,
+		endRules:         e.endRules,
+		endFileRules:     e.endFileRules,
+		fuzzing:          e.fuzzing,
+		printFmt:         newPrintFormatter(stdout),
+		namedMatchGroups: make(map[string]string),
+	}
+}
+
+func EvalProgram(progSrc string, files []InputFile, rootSelectors []RootSelector, stdout io.Writer, fuzzing bool) (*Evaluator, error) {
+	prog, lex, err := loadInlineProgram(progSrc)
 	if err != nil {
 		return nil, err
 	}
-	ev := NewEvaluator(prog, &lex, stdout)
+	return EvalParsedProgram(prog, lex, files, rootSelectors, stdout, fuzzing)
+}
+
+// EvalParsedProgram runs an already-parsed Program, same as EvalProgram but
+// for a caller that built prog/lex itself - cli.Run does, through a
+// ProgramLoader, so that -f's `include` directives and multiple -f files
+// are resolved and merged before evaluation starts.
+func EvalParsedProgram(prog Program, lex *Lexer, files []InputFile, rootSelectors []RootSelector, stdout io.Writer, fuzzing bool) (*Evaluator, error) {
+	ev, err := NewEvaluator(prog, lex, stdout)
+	if err != nil {
+		return &ev, err
+	}
 	ev.fuzzing = fuzzing
+	defer func() { ev.printFmt.finish() }()
 
 	// begin rules
 	for _, rule := range ev.beginRules {
@@ -1239,12 +2084,38 @@ func EvalProgram(progSrc string, files []InputFile, rootSelectors []string, stdo
 		}
 	}
 
+	batch := make([]recordTask, 0, Jobs)
+	flushBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := ev.runRecordBatch(batch, stdout)
+		batch = batch[:0]
+		return err
+	}
+
 	// for each file, run the pattern rules
 	for _, file := range files {
-		// for each json value
-		jp := newJsonParser(file.NewReader())
+		format := ""
+		if hint, ok := file.(FormatHint); ok {
+			format = hint.Format()
+		}
+		if format == "" {
+			format = InputFormatOverride
+		}
+		reader := file.NewReader()
+		if format == "" {
+			format = DetectFormat(file.Name())
+			format, reader = sniffFormat(format, reader)
+		}
+		dec, err := NewDecoder(format, reader, DecoderOptions{CSVHasHeader: CSVHasHeader, CSVDelimiter: CSVDelimiter})
+		if err != nil {
+			return &ev, err
+		}
+
+		// for each value the decoder produces
 		for {
-			rootValue, err := jp.next()
+			rootValue, err := dec.Next()
 			if err != nil {
 				if err == io.EOF {
 					break
@@ -1258,11 +2129,11 @@ func EvalProgram(progSrc string, files []InputFile, rootSelectors []string, stdo
 			rootCells := make([]*Cell, 0)
 			if len(rootSelectors) > 0 {
 				for _, rootSelector := range rootSelectors {
-					cell, err := EvalExpression(rootSelector, rootValue, stdout)
+					cells, err := EvalRootSelector(rootSelector, rootValue, stdout)
 					if err != nil {
 						return &ev, err
 					}
-					rootCells = append(rootCells, cell)
+					rootCells = append(rootCells, cells...)
 				}
 			} else {
 				rootCells = append(rootCells, NewCell(rootValue))
@@ -1282,19 +2153,35 @@ func EvalProgram(progSrc string, files []InputFile, rootSelectors []string, stdo
 					}
 				}
 
-				// run the rules
-				ev.root = rootCell
-				if err := ev.evalPatternRules(ev.patternRules); err != nil {
-					if err == errExit {
-						return &ev, nil
+				if Jobs <= 1 {
+					// run the rules
+					ev.root = rootCell
+					if err := ev.evalPatternRules(ev.patternRules); err != nil {
+						if err == errExit {
+							return &ev, nil
+						}
+						return &ev, err
+					}
+
+					// run the end file rules
+					for _, rule := range ev.endFileRules {
+						ev.ruleRoot = NewCell(rootVal)
+						if err := ev.evalStatement(rule.Body); err != nil {
+							if err == errExit {
+								return &ev, nil
+							}
+							return &ev, err
+						}
 					}
-					return &ev, err
+					continue
 				}
 
-				// run the end file rules
-				for _, rule := range ev.endFileRules {
-					ev.ruleRoot = NewCell(rootVal)
-					if err := ev.evalStatement(rule.Body); err != nil {
+				// --jobs > 1: queue the record and let runRecordBatch run
+				// its pattern rules and end file rules once the batch fills
+				// up (or the file runs out of records, below).
+				batch = append(batch, recordTask{rootCell, rootVal})
+				if len(batch) >= Jobs {
+					if err := flushBatch(); err != nil {
 						if err == errExit {
 							return &ev, nil
 						}
@@ -1303,6 +2190,13 @@ func EvalProgram(progSrc string, files []InputFile, rootSelectors []string, stdo
 				}
 			}
 		}
+
+		if err := flushBatch(); err != nil {
+			if err == errExit {
+				return &ev, nil
+			}
+			return &ev, err
+		}
 	}
 
 	// end rules
@@ -1318,3 +2212,112 @@ func EvalProgram(progSrc string, files []InputFile, rootSelectors []string, stdo
 
 	return &ev, nil
 }
+
+// NewReplEvaluator creates a persistent Evaluator for an interactive
+// session: it starts out with an empty program and a nil $, and grows its
+// program source one line at a time through EvalLine instead of evaluating
+// a single fixed program.
+func NewReplEvaluator(stdout io.Writer) (*Evaluator, error) {
+	lex := NewLexer("")
+	ev, err := NewEvaluator(Program{}, &lex, stdout)
+	if err != nil {
+		return &ev, err
+	}
+	ev.root = NewCell(NewValue(nil))
+	ev.ruleRoot = ev.root
+	return &ev, nil
+}
+
+// SetRoot replaces $ for subsequent EvalLine calls, e.g. after a REPL
+// ":load" command decodes a file.
+func (e *Evaluator) SetRoot(v Value) {
+	cell := NewCell(v)
+	e.root = cell
+	e.ruleRoot = cell
+}
+
+// Root returns the current value of $.
+func (e *Evaluator) Root() Value {
+	if e.root == nil {
+		return NewValue(nil)
+	}
+	return e.root.Value
+}
+
+// FunctionNames lists the user-defined functions visible in the REPL, in
+// declaration order.
+func (e *Evaluator) FunctionNames() []string {
+	names := make([]string, 0, len(e.prog.Functions))
+	for _, fn := range e.prog.Functions {
+		names = append(names, e.lexer.GetString(&fn.ident))
+	}
+	return names
+}
+
+// EvalLine evaluates one line of REPL input against $ and returns its
+// result. When isProgram is true, src is parsed as a full program (rules
+// and/or function declarations, e.g. `{ ... }` or `BEGIN { ... }`);
+// otherwise it's parsed as a single expression.
+//
+// Rather than lexing each line in isolation, the line is appended to the
+// evaluator's running source buffer and the whole buffer is re-parsed.
+// Since earlier lines are an unchanged prefix of the new source, their
+// tokens keep the same positions - which is what lets a function or
+// variable defined on one line keep resolving correctly (via e.lexer) on
+// later lines. Only the rules introduced by this line are executed; rules
+// from earlier lines were already run when they were typed.
+func (e *Evaluator) EvalLine(src string, isProgram bool) (*Cell, error) {
+	if !isProgram {
+		return e.evalReplExpr(src)
+	}
+
+	prevRuleCount := len(e.prog.Rules)
+
+	lex := NewLexer(e.lexer.src + src + "\n")
+	parser := NewParser(&lex)
+	prog, err := parser.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	e.lexer = &lex
+	e.prog = prog
+	e.readRules()
+	e.addProgramFunctions()
+
+	for i := prevRuleCount; i < len(prog.Rules); i++ {
+		rule := prog.Rules[i]
+		e.ruleRoot = e.root
+		switch rule.Kind {
+		case BeginRule, EndRule:
+			if err := e.evalStatement(rule.Body); err != nil && err != errNext && err != errExit {
+				return nil, err
+			}
+		default:
+			if err := e.evalRules([]*Rule{&rule}); err != nil && err != errExit {
+				return nil, err
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// evalReplExpr parses src as a single expression and evaluates it against
+// $, growing the evaluator's source buffer the same way EvalLine does.
+func (e *Evaluator) evalReplExpr(src string) (*Cell, error) {
+	lex := NewLexer(e.lexer.src + src + "\n")
+	parser := NewParser(&lex)
+	expr, err := parser.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+
+	e.lexer = &lex
+	e.ruleRoot = e.root
+	cell, err := e.evalExpr(expr)
+	if err != nil && err != errExit {
+		return nil, err
+	}
+	return cell, nil
+}
@@ -0,0 +1,131 @@
+package lang
+
+import "sort"
+
+// File tracks one source file's text plus the byte offset of every line
+// start, so a byte position can be resolved to a (line, col, srcLine) in
+// O(log n) by binary-searching that offset table instead of rescanning the
+// whole source for every diagnostic.
+type File struct {
+	Name string
+	Base int
+	Src  string
+	// lineOffsets[i] is the local byte offset of the start of line i+1.
+	// lineOffsets[0] is always 0.
+	lineOffsets []int
+}
+
+func newFile(name string, base int, src string) *File {
+	return &File{Name: name, Base: base, Src: src, lineOffsets: []int{0}}
+}
+
+// AddLine records that a new line starts at the given local byte offset.
+// The lexer calls this every time it consumes a '\n', including ones
+// inside a string or regex literal. Offsets must be non-decreasing;
+// anything out of order is ignored rather than corrupting the table.
+func (f *File) AddLine(offset int) {
+	if n := len(f.lineOffsets); n > 0 && f.lineOffsets[n-1] >= offset {
+		return
+	}
+	f.lineOffsets = append(f.lineOffsets, offset)
+}
+
+// Position resolves a global pos (Base + local offset) to a 1-based line
+// number, a 0-based column, and the text of that line with its
+// terminating newline stripped.
+func (f *File) Position(pos int) (line, col int, srcLine string) {
+	local := pos - f.Base
+	if local < 0 {
+		local = 0
+	} else if local > len(f.Src) {
+		local = len(f.Src)
+	}
+
+	i := sort.Search(len(f.lineOffsets), func(i int) bool {
+		return f.lineOffsets[i] > local
+	}) - 1
+	if i < 0 {
+		i = 0
+	}
+
+	lineStart := f.lineOffsets[i]
+	lineEnd := len(f.Src)
+	if i+1 < len(f.lineOffsets) {
+		// the next recorded line start is right after this line's '\n'
+		lineEnd = f.lineOffsets[i+1] - 1
+		if lineEnd < lineStart {
+			lineEnd = lineStart
+		}
+	}
+
+	return i + 1, local - lineStart, f.Src[lineStart:lineEnd]
+}
+
+// FileSet assigns every File it holds a disjoint range of the global Pos
+// address space via Base, so a bare int Pos unambiguously identifies both a
+// file and an offset within it. This lets tokens from the program source,
+// -f includes and -r/-P root selectors all carry a plain int Pos while
+// still being traceable back to the file they came from.
+type FileSet struct {
+	files []*File
+}
+
+// NewFileSet returns an empty FileSet.
+func NewFileSet() *FileSet {
+	return &FileSet{}
+}
+
+// AddFile registers src under name and returns a File whose Pos range
+// starts one byte past the previous file's end, so an EOF token (whose Pos
+// is one past the last byte) still resolves back to the file it ended in
+// rather than bleeding into the next one.
+func (fs *FileSet) AddFile(name, src string) *File {
+	base := 0
+	if n := len(fs.files); n > 0 {
+		last := fs.files[n-1]
+		base = last.Base + len(last.Src) + 1
+	}
+	f := newFile(name, base, src)
+	fs.files = append(fs.files, f)
+	return f
+}
+
+// File returns the File that owns pos, or nil if pos falls outside every
+// file registered so far.
+func (fs *FileSet) File(pos int) *File {
+	i := sort.Search(len(fs.files), func(i int) bool {
+		return fs.files[i].Base > pos
+	}) - 1
+	if i < 0 || i >= len(fs.files) {
+		return nil
+	}
+	return fs.files[i]
+}
+
+// Position resolves pos through whichever File owns it. If no File owns
+// pos (an empty FileSet, or a stale pos from a different one), it returns
+// the zero line/col and an empty srcLine.
+func (fs *FileSet) Position(pos int) (file *File, line, col int, srcLine string) {
+	f := fs.File(pos)
+	if f == nil {
+		return nil, 0, 0, ""
+	}
+	line, col, srcLine = f.Position(pos)
+	return f, line, col, srcLine
+}
+
+// Lexeme returns the source text a token spans, resolved through whichever
+// File owns tok.Pos. This is what makes a multi-file Program work with a
+// single *Lexer: every Lexer sharing this FileSet produces tokens whose
+// lexeme can be recovered here regardless of which file's lexer read them.
+func (fs *FileSet) Lexeme(tok Token) string {
+	f := fs.File(tok.Pos)
+	if f == nil {
+		return ""
+	}
+	local := tok.Pos - f.Base
+	if local < 0 || local+tok.Len > len(f.Src) {
+		return ""
+	}
+	return f.Src[local : local+tok.Len]
+}
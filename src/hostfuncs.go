@@ -0,0 +1,149 @@
+package lang
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// HostFuncs is set by an embedder before calling EvalProgram or
+// EvalParsedProgram to expose ordinary Go functions as script-callable
+// globals, the same way addRuntimeFunctions installs jqawk's own builtins -
+// just reachable from outside the package, so a Go program embedding jqawk
+// as a scripting layer doesn't have to hand-write a NativeFn adapter for
+// every function it wants to expose. Keys are the names scripts call the
+// functions under.
+//
+// Each function is invoked through reflect.Call: arguments are converted
+// from Value to the parameter's Go type (see valueToReflect), and the
+// result is converted back via NewValue, the same constructor jqawk's own
+// code already uses to turn plain Go values into Values. A function may
+// optionally return an error as its last result; a non-nil one becomes a
+// script-level runtime error instead of a return value. Variadic functions
+// aren't supported.
+var HostFuncs map[string]interface{}
+
+// registerHostFuncs wraps every entry in HostFuncs and installs it into e's
+// root frame, called once from NewEvaluator right after addRuntimeFunctions
+// and addProgramFunctions.
+func registerHostFuncs(e *Evaluator) error {
+	for name, fn := range HostFuncs {
+		native, err := wrapHostFunc(name, fn)
+		if err != nil {
+			return err
+		}
+		e.stackTop.locals[name] = NewCell(Value{
+			Tag:      ValueNativeFn,
+			NativeFn: native,
+		})
+	}
+	return nil
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// wrapHostFunc builds the NativeFn that calls fn via reflection, validating
+// its shape up front so a bad HostFuncs entry fails at setup time rather
+// than on the first script call.
+func wrapHostFunc(name string, fn interface{}) (NativeFn, error) {
+	fnVal := reflect.ValueOf(fn)
+	fnType := fnVal.Type()
+	if fnType.Kind() != reflect.Func {
+		return nil, fmt.Errorf("host function %q is a %s, not a function", name, fnType.Kind())
+	}
+	if fnType.IsVariadic() {
+		return nil, fmt.Errorf("host function %q: variadic functions aren't supported", name)
+	}
+
+	returnsError := fnType.NumOut() > 0 && fnType.Out(fnType.NumOut()-1) == errorType
+
+	return func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+		if err := checkArgCount(args, fnType.NumIn()); err != nil {
+			return nil, fmt.Errorf("%s(): %s", name, err.Error())
+		}
+
+		in := make([]reflect.Value, fnType.NumIn())
+		for i, arg := range args {
+			v, err := valueToReflect(arg, fnType.In(i))
+			if err != nil {
+				return nil, fmt.Errorf("%s(): argument %d: %s", name, i+1, err.Error())
+			}
+			in[i] = v
+		}
+
+		out := fnVal.Call(in)
+
+		if returnsError {
+			if errOut := out[len(out)-1]; !errOut.IsNil() {
+				return nil, errOut.Interface().(error)
+			}
+			out = out[:len(out)-1]
+		}
+
+		switch len(out) {
+		case 0:
+			return nil, nil
+		case 1:
+			result := NewValue(out[0].Interface())
+			return &result, nil
+		default:
+			values := make([]interface{}, len(out))
+			for i, o := range out {
+				values[i] = o.Interface()
+			}
+			result := NewValue(values)
+			return &result, nil
+		}
+	}, nil
+}
+
+// valueToReflect converts a jqawk Value into a reflect.Value a host
+// function's parameter type t can accept. It goes through the same native
+// Go types NewValue already knows how to build a Value back out of
+// (float64, string, bool, []interface{}, map[string]interface{}), so a host
+// function taking e.g. an int or a []string still works via
+// reflect.Value.Convert.
+func valueToReflect(v *Value, t reflect.Type) (reflect.Value, error) {
+	var native interface{}
+	switch v.Tag {
+	case ValueStr, ValueRegex:
+		native = *v.Str
+	case ValueNum:
+		native = v.asFloat64()
+	case ValueBool:
+		native = *v.Bool
+	case ValueNil, ValueUnknown:
+		native = nil
+	case ValueArray:
+		items := make([]interface{}, len(v.Array))
+		for i, cell := range v.Array {
+			item, err := valueToReflect(&cell.Value, reflect.TypeOf((*interface{})(nil)).Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			items[i] = item.Interface()
+		}
+		native = items
+	case ValueObj:
+		obj := make(map[string]interface{}, len(*v.Obj))
+		for _, key := range v.ObjKeys {
+			item, err := valueToReflect(&(*v.Obj)[key].Value, reflect.TypeOf((*interface{})(nil)).Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			obj[key] = item.Interface()
+		}
+		native = obj
+	default:
+		return reflect.Value{}, fmt.Errorf("cannot pass a %s to a host function", v.Tag)
+	}
+
+	if native == nil {
+		return reflect.Zero(t), nil
+	}
+
+	rv := reflect.ValueOf(native)
+	if !rv.Type().ConvertibleTo(t) {
+		return reflect.Value{}, fmt.Errorf("cannot convert %s to %s", rv.Type(), t)
+	}
+	return rv.Convert(t), nil
+}
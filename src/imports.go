@@ -0,0 +1,441 @@
+package lang
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alligator/jqawk/src/modules"
+)
+
+// ModuleSearchPaths holds the `-I` directories jqawk was invoked with, ahead
+// of JQAWK_PATH. The CLI sets this before running a program; it lives here
+// rather than threading a parameter through EvalProgram/EvalProgramStream
+// because those are also called from the REPL and test harness with no
+// natural place to plumb per-invocation config through.
+var ModuleSearchPaths []string
+
+// runtimeBuiltinNames are the locals addRuntimeFunctions seeds every stack
+// frame with - resolveImports needs to tell them apart from a source
+// module's own exports when harvesting its root frame.
+var runtimeBuiltinNames = map[string]bool{
+	"printf":   true,
+	"sprintf":  true,
+	"json":     true,
+	"num":      true,
+	"stream":   true,
+	"jq":       true,
+	"yaml":     true,
+	"toml":     true,
+	"csv":      true,
+	"emit":     true,
+	"jsonpath": true,
+}
+
+// resolveImports runs each top-level `import` in e.prog, binding the
+// resulting module object into e.stackTop.locals under its alias (or, for
+// a bare `import "path"`, a name derived from path).
+func (e *Evaluator) resolveImports() error {
+	for _, imp := range e.prog.Imports {
+		alias := imp.Alias
+		if alias == "" {
+			alias = modules.DefaultName(imp.Path)
+		}
+
+		exports, err := resolveModule(imp.Path)
+		if err != nil {
+			return e.error(imp.Token(), err.Error())
+		}
+
+		obj := NewObject()
+		keys := make([]string, 0, len(exports))
+		for k := range exports {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			(*obj.Obj)[k] = exports[k]
+		}
+		obj.ObjKeys = keys
+
+		e.stackTop.locals[alias] = NewCell(obj)
+	}
+	return nil
+}
+
+func resolveModule(path string) (map[string]*Cell, error) {
+	if builtin, ok := builtinModule(path); ok {
+		return builtin, nil
+	}
+
+	resolver := modules.NewResolver(ModuleSearchPaths)
+	file, err := resolver.ResolveFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return evalModuleFile(file)
+}
+
+// evalModuleFile parses and runs a source module's BEGIN blocks in a fresh
+// Evaluator, then harvests its function declarations and any globals its
+// BEGIN blocks assigned as the module's exports.
+func evalModuleFile(file string) (map[string]*Cell, error) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read module %q: %s", file, err.Error())
+	}
+
+	lex := NewLexer(string(src))
+	parser := NewParser(&lex)
+	prog, err := parser.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	var discard bytes.Buffer
+	sub, err := NewEvaluator(prog, &lex, &discard)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range sub.beginRules {
+		sub.ruleRoot = NewCell(NewValue(nil))
+		if err := sub.evalStatement(rule.Body); err != nil && err != errExit {
+			return nil, err
+		}
+	}
+
+	exports := make(map[string]*Cell)
+	for name, cell := range sub.stackTop.locals {
+		if runtimeBuiltinNames[name] {
+			continue
+		}
+		exports[name] = cell
+	}
+	return exports, nil
+}
+
+func builtinModule(name string) (map[string]*Cell, bool) {
+	switch name {
+	case "strings":
+		return stringsModule(), true
+	case "math":
+		return mathModule(), true
+	case "time":
+		return timeModule(), true
+	case "csv":
+		return csvModule(), true
+	case "hash":
+		return hashModule(), true
+	case "base64":
+		return base64Module(), true
+	default:
+		return nil, false
+	}
+}
+
+func nativeFnCell(fn func(*Evaluator, []*Value, *Value) (*Value, error)) *Cell {
+	return NewCell(Value{Tag: ValueNativeFn, NativeFn: fn})
+}
+
+func stringsModule() map[string]*Cell {
+	return map[string]*Cell{
+		"trim": nativeFnCell(func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+			if err := checkArgCount(args, 1); err != nil {
+				return nil, err
+			}
+			arg, err := checkArg(args, 0, ValueStr)
+			if err != nil {
+				return nil, err
+			}
+			v := NewValue(strings.TrimSpace(*arg.Str))
+			return &v, nil
+		}),
+		"replace": nativeFnCell(func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+			if err := checkArgCount(args, 3); err != nil {
+				return nil, err
+			}
+			s, err := checkArg(args, 0, ValueStr)
+			if err != nil {
+				return nil, err
+			}
+			old, err := checkArg(args, 1, ValueStr)
+			if err != nil {
+				return nil, err
+			}
+			new_, err := checkArg(args, 2, ValueStr)
+			if err != nil {
+				return nil, err
+			}
+			v := NewValue(strings.ReplaceAll(*s.Str, *old.Str, *new_.Str))
+			return &v, nil
+		}),
+		"startsWith": nativeFnCell(func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+			if err := checkArgCount(args, 2); err != nil {
+				return nil, err
+			}
+			s, err := checkArg(args, 0, ValueStr)
+			if err != nil {
+				return nil, err
+			}
+			prefix, err := checkArg(args, 1, ValueStr)
+			if err != nil {
+				return nil, err
+			}
+			v := NewValue(strings.HasPrefix(*s.Str, *prefix.Str))
+			return &v, nil
+		}),
+		"padLeft": nativeFnCell(func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+			if len(args) < 2 || len(args) > 3 {
+				return nil, fmt.Errorf("expected 2 or 3 argument(s)")
+			}
+			s, err := checkArg(args, 0, ValueStr)
+			if err != nil {
+				return nil, err
+			}
+			widthArg, err := checkArg(args, 1, ValueNum)
+			if err != nil {
+				return nil, err
+			}
+			pad := " "
+			if len(args) == 3 {
+				padArg, err := checkArg(args, 2, ValueStr)
+				if err != nil {
+					return nil, err
+				}
+				pad = *padArg.Str
+			}
+			if pad == "" {
+				pad = " "
+			}
+
+			str := *s.Str
+			width := int(*widthArg.Num)
+			for len(str) < width {
+				str = pad + str
+			}
+			if len(str) > width {
+				str = str[len(str)-width:]
+			}
+
+			v := NewValue(str)
+			return &v, nil
+		}),
+	}
+}
+
+func mathFn1(f func(float64) float64) func(*Evaluator, []*Value, *Value) (*Value, error) {
+	return func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+		if err := checkArgCount(args, 1); err != nil {
+			return nil, err
+		}
+		arg, err := checkArg(args, 0, ValueNum)
+		if err != nil {
+			return nil, err
+		}
+		v := NewValue(f(*arg.Num))
+		return &v, nil
+	}
+}
+
+func mathModule() map[string]*Cell {
+	return map[string]*Cell{
+		"min": nativeFnCell(func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+			if err := checkArgCount(args, 2); err != nil {
+				return nil, err
+			}
+			a, err := checkArg(args, 0, ValueNum)
+			if err != nil {
+				return nil, err
+			}
+			b, err := checkArg(args, 1, ValueNum)
+			if err != nil {
+				return nil, err
+			}
+			v := NewValue(math.Min(*a.Num, *b.Num))
+			return &v, nil
+		}),
+		"max": nativeFnCell(func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+			if err := checkArgCount(args, 2); err != nil {
+				return nil, err
+			}
+			a, err := checkArg(args, 0, ValueNum)
+			if err != nil {
+				return nil, err
+			}
+			b, err := checkArg(args, 1, ValueNum)
+			if err != nil {
+				return nil, err
+			}
+			v := NewValue(math.Max(*a.Num, *b.Num))
+			return &v, nil
+		}),
+		"abs":  nativeFnCell(mathFn1(math.Abs)),
+		"log":  nativeFnCell(mathFn1(math.Log)),
+		"sqrt": nativeFnCell(mathFn1(math.Sqrt)),
+		"pow": nativeFnCell(func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+			if err := checkArgCount(args, 2); err != nil {
+				return nil, err
+			}
+			base, err := checkArg(args, 0, ValueNum)
+			if err != nil {
+				return nil, err
+			}
+			exp, err := checkArg(args, 1, ValueNum)
+			if err != nil {
+				return nil, err
+			}
+			v := NewValue(math.Pow(*base.Num, *exp.Num))
+			return &v, nil
+		}),
+		"PI": NewCell(NewValue(math.Pi)),
+	}
+}
+
+func timeModule() map[string]*Cell {
+	return map[string]*Cell{
+		"now": nativeFnCell(func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+			if err := checkArgCount(args, 0); err != nil {
+				return nil, err
+			}
+			v := NewValue(float64(time.Now().Unix()))
+			return &v, nil
+		}),
+		"parse": nativeFnCell(func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+			if err := checkArgCount(args, 2); err != nil {
+				return nil, err
+			}
+			str, err := checkArg(args, 0, ValueStr)
+			if err != nil {
+				return nil, err
+			}
+			layout, err := checkArg(args, 1, ValueStr)
+			if err != nil {
+				return nil, err
+			}
+			t, err := time.Parse(*layout.Str, *str.Str)
+			if err != nil {
+				return nil, err
+			}
+			v := NewValue(float64(t.Unix()))
+			return &v, nil
+		}),
+		"format": nativeFnCell(func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+			if err := checkArgCount(args, 2); err != nil {
+				return nil, err
+			}
+			ts, err := checkArg(args, 0, ValueNum)
+			if err != nil {
+				return nil, err
+			}
+			layout, err := checkArg(args, 1, ValueStr)
+			if err != nil {
+				return nil, err
+			}
+			v := NewValue(time.Unix(int64(*ts.Num), 0).UTC().Format(*layout.Str))
+			return &v, nil
+		}),
+	}
+}
+
+func csvModule() map[string]*Cell {
+	return map[string]*Cell{
+		"parse": nativeFnCell(func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+			if err := checkArgCount(args, 1); err != nil {
+				return nil, err
+			}
+			arg, err := checkArg(args, 0, ValueStr)
+			if err != nil {
+				return nil, err
+			}
+			rows, err := parseCSV(*arg.Str, false)
+			if err != nil {
+				return nil, err
+			}
+			return &rows, nil
+		}),
+		"encode": nativeFnCell(func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+			if err := checkArgCount(args, 1); err != nil {
+				return nil, err
+			}
+			rows, err := checkArg(args, 0, ValueArray)
+			if err != nil {
+				return nil, err
+			}
+			str, err := encodeCSV(rows)
+			if err != nil {
+				return nil, err
+			}
+			v := NewValue(str)
+			return &v, nil
+		}),
+	}
+}
+
+func hashModule() map[string]*Cell {
+	return map[string]*Cell{
+		"sha256": nativeFnCell(func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+			if err := checkArgCount(args, 1); err != nil {
+				return nil, err
+			}
+			arg, err := checkArg(args, 0, ValueStr)
+			if err != nil {
+				return nil, err
+			}
+			sum := sha256.Sum256([]byte(*arg.Str))
+			v := NewValue(hex.EncodeToString(sum[:]))
+			return &v, nil
+		}),
+		"md5": nativeFnCell(func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+			if err := checkArgCount(args, 1); err != nil {
+				return nil, err
+			}
+			arg, err := checkArg(args, 0, ValueStr)
+			if err != nil {
+				return nil, err
+			}
+			sum := md5.Sum([]byte(*arg.Str))
+			v := NewValue(hex.EncodeToString(sum[:]))
+			return &v, nil
+		}),
+	}
+}
+
+func base64Module() map[string]*Cell {
+	return map[string]*Cell{
+		"encode": nativeFnCell(func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+			if err := checkArgCount(args, 1); err != nil {
+				return nil, err
+			}
+			arg, err := checkArg(args, 0, ValueStr)
+			if err != nil {
+				return nil, err
+			}
+			v := NewValue(base64.StdEncoding.EncodeToString([]byte(*arg.Str)))
+			return &v, nil
+		}),
+		"decode": nativeFnCell(func(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+			if err := checkArgCount(args, 1); err != nil {
+				return nil, err
+			}
+			arg, err := checkArg(args, 0, ValueStr)
+			if err != nil {
+				return nil, err
+			}
+			decoded, err := base64.StdEncoding.DecodeString(*arg.Str)
+			if err != nil {
+				return nil, err
+			}
+			v := NewValue(string(decoded))
+			return &v, nil
+		}),
+	}
+}
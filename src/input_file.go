@@ -10,28 +10,57 @@ type InputFile interface {
 	NewReader() io.Reader
 }
 
+// FormatHint is an InputFile that knows its own decoding format, which
+// EvalParsedProgram prefers over -F/InputFormatOverride and extension-based
+// DetectFormat for that one file - for a caller juggling several input
+// files in different formats in the same run, where a single -F override
+// can't apply to all of them. Format returns "" to defer to the usual
+// override/auto-detect resolution, same as an InputFile that doesn't
+// implement this interface at all.
+type FormatHint interface {
+	Format() string
+}
+
 type StreamingInputFile struct {
 	name   string
 	reader io.Reader
+	format string
 }
 
 func NewStreamingInputFile(name string, reader io.Reader) InputFile {
-	return &StreamingInputFile{name, reader}
+	return &StreamingInputFile{name: name, reader: reader}
+}
+
+// NewStreamingInputFileWithFormat is NewStreamingInputFile, pinned to a
+// specific decoding format (see FormatHint) instead of deferring to -F or
+// name's extension.
+func NewStreamingInputFileWithFormat(name string, reader io.Reader, format string) InputFile {
+	return &StreamingInputFile{name: name, reader: reader, format: format}
 }
 func (f *StreamingInputFile) Name() string { return f.name }
 func (f *StreamingInputFile) NewReader() io.Reader {
 	return f.reader
 }
+func (f *StreamingInputFile) Format() string { return f.format }
 
 type BufferedInputFile struct {
 	name    string
 	content []byte
+	format  string
 }
 
 func NewBufferedInputFile(name string, content []byte) InputFile {
-	return &BufferedInputFile{name, content}
+	return &BufferedInputFile{name: name, content: content}
+}
+
+// NewBufferedInputFileWithFormat is NewBufferedInputFile, pinned to a
+// specific decoding format (see FormatHint) instead of deferring to -F or
+// name's extension.
+func NewBufferedInputFileWithFormat(name string, content []byte, format string) InputFile {
+	return &BufferedInputFile{name: name, content: content, format: format}
 }
 func (f *BufferedInputFile) Name() string { return f.name }
 func (f *BufferedInputFile) NewReader() io.Reader {
 	return bytes.NewReader(f.content)
 }
+func (f *BufferedInputFile) Format() string { return f.format }
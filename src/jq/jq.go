@@ -0,0 +1,373 @@
+// Package jq compiles a small subset of jq's filter language into a
+// callable Filter, backing the `jq(...)` builtin described in the jq filter
+// request: identity `.`, field access `.a.b`, iteration `.[]`, pipe `|`,
+// `select(f)`, `map(f)`, `length` and `keys`, plus the comparison and
+// arithmetic operators select's predicates need. Anything outside that
+// (string interpolation, jq's `and`/`or`/`not`, object construction,
+// slices, reduce/foreach) reports a compile error instead of silently
+// compiling to the wrong thing - widen this as more of jq's surface is
+// needed.
+//
+// jq operates on its own Value type rather than lang.Value so that this
+// package has no dependency on src (which needs to depend on jq to expose
+// the builtin) - the same vendored-runtime split src/compiler uses to keep
+// its generated programs free of a lang import.
+package jq
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Kind identifies the shape of a Value, mirroring lang's ValueTag closely
+// enough that the adapter in jqfilter.go is a straight switch-to-switch copy.
+type Kind int
+
+const (
+	KindNil Kind = iota
+	KindStr
+	KindNum
+	KindBool
+	KindArray
+	KindObject
+)
+
+// Value is jq's own boxed value - deliberately independent of lang.Value,
+// see the package doc comment for why.
+type Value struct {
+	Kind   Kind
+	Str    string
+	Num    float64
+	Bool   bool
+	Array  []Value
+	Object map[string]Value
+	Keys   []string // preserves object insertion order, like lang.Value.ObjKeys
+}
+
+func Nil() Value            { return Value{Kind: KindNil} }
+func Str(s string) Value    { return Value{Kind: KindStr, Str: s} }
+func Num(n float64) Value   { return Value{Kind: KindNum, Num: n} }
+func Bool(b bool) Value     { return Value{Kind: KindBool, Bool: b} }
+func Array(vs []Value) Value {
+	return Value{Kind: KindArray, Array: vs}
+}
+
+// Object builds an object value from keys/vals in order, the same
+// ordered-keys-plus-map shape lang.Value keeps in ObjKeys/Obj.
+func Object(keys []string, vals map[string]Value) Value {
+	return Value{Kind: KindObject, Keys: keys, Object: vals}
+}
+
+// Filter is a compiled jq expression. Applying it to a value yields the
+// (possibly several) values it produces, flattening jq's output stream into
+// a slice since Value has no lazy iterator of its own.
+type Filter func(in Value) ([]Value, error)
+
+// Compile parses src as a jq filter and returns the Filter it describes.
+func Compile(src string) (Filter, error) {
+	toks, err := lexJq(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	filter, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	if !p.atEnd() {
+		return nil, fmt.Errorf("jq: unexpected input near %q", p.peek().text)
+	}
+	return filter, nil
+}
+
+func truthy(v Value) bool {
+	switch v.Kind {
+	case KindBool:
+		return v.Bool
+	case KindNum:
+		return v.Num != 0
+	case KindStr:
+		return len(v.Str) > 0
+	case KindNil:
+		return false
+	default:
+		return true
+	}
+}
+
+func kindName(k Kind) string {
+	switch k {
+	case KindNil:
+		return "null"
+	case KindStr:
+		return "string"
+	case KindNum:
+		return "number"
+	case KindBool:
+		return "boolean"
+	case KindArray:
+		return "array"
+	case KindObject:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
+
+// chain runs base over in, then runs step over every value base produced,
+// concatenating the results - the building block every filter composes
+// with, since jq filters are all "one value in, many values out".
+func chain(base Filter, step Filter) Filter {
+	return func(in Value) ([]Value, error) {
+		mids, err := base(in)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]Value, 0, len(mids))
+		for _, mid := range mids {
+			vals, err := step(mid)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, vals...)
+		}
+		return out, nil
+	}
+}
+
+func identity(in Value) ([]Value, error) {
+	return []Value{in}, nil
+}
+
+func field(name string) Filter {
+	return func(in Value) ([]Value, error) {
+		if in.Kind == KindNil {
+			return []Value{Nil()}, nil
+		}
+		if in.Kind != KindObject {
+			return nil, fmt.Errorf("jq: cannot index %s with \"%s\"", kindName(in.Kind), name)
+		}
+		if v, ok := in.Object[name]; ok {
+			return []Value{v}, nil
+		}
+		return []Value{Nil()}, nil
+	}
+}
+
+func iterate(in Value) ([]Value, error) {
+	switch in.Kind {
+	case KindArray:
+		return append([]Value(nil), in.Array...), nil
+	case KindObject:
+		out := make([]Value, 0, len(in.Keys))
+		for _, k := range in.Keys {
+			out = append(out, in.Object[k])
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("jq: cannot iterate over %s", kindName(in.Kind))
+	}
+}
+
+func selectFilter(cond Filter) Filter {
+	return func(in Value) ([]Value, error) {
+		results, err := cond(in)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			if truthy(r) {
+				return []Value{in}, nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+func mapFilter(inner Filter) Filter {
+	return func(in Value) ([]Value, error) {
+		if in.Kind != KindArray {
+			return nil, fmt.Errorf("jq: map requires an array, got %s", kindName(in.Kind))
+		}
+		out := make([]Value, 0, len(in.Array))
+		for _, item := range in.Array {
+			results, err := inner(item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, results...)
+		}
+		return []Value{Array(out)}, nil
+	}
+}
+
+func lengthFilter(in Value) ([]Value, error) {
+	switch in.Kind {
+	case KindArray:
+		return []Value{Num(float64(len(in.Array)))}, nil
+	case KindObject:
+		return []Value{Num(float64(len(in.Keys)))}, nil
+	case KindStr:
+		return []Value{Num(float64(len(in.Str)))}, nil
+	case KindNil:
+		return []Value{Num(0)}, nil
+	default:
+		return nil, fmt.Errorf("jq: length: unsupported type %s", kindName(in.Kind))
+	}
+}
+
+func keysFilter(in Value) ([]Value, error) {
+	if in.Kind != KindObject {
+		return nil, fmt.Errorf("jq: keys requires an object, got %s", kindName(in.Kind))
+	}
+	keys := append([]string(nil), in.Keys...)
+	sort.Strings(keys)
+	out := make([]Value, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, Str(k))
+	}
+	return []Value{Array(out)}, nil
+}
+
+func literal(v Value) Filter {
+	return func(Value) ([]Value, error) {
+		return []Value{v}, nil
+	}
+}
+
+func asFloat(v Value) (float64, bool) {
+	switch v.Kind {
+	case KindNum:
+		return v.Num, true
+	case KindBool:
+		if v.Bool {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+func compareValues(op string, l, r Value) (Value, error) {
+	if l.Kind == KindStr && r.Kind == KindStr {
+		switch op {
+		case "==":
+			return Bool(l.Str == r.Str), nil
+		case "!=":
+			return Bool(l.Str != r.Str), nil
+		case "<":
+			return Bool(l.Str < r.Str), nil
+		case "<=":
+			return Bool(l.Str <= r.Str), nil
+		case ">":
+			return Bool(l.Str > r.Str), nil
+		case ">=":
+			return Bool(l.Str >= r.Str), nil
+		}
+	}
+
+	lf, lok := asFloat(l)
+	rf, rok := asFloat(r)
+	if !lok || !rok {
+		switch op {
+		case "==":
+			return Bool(false), nil
+		case "!=":
+			return Bool(true), nil
+		default:
+			return Value{}, fmt.Errorf("jq: cannot compare %s and %s", kindName(l.Kind), kindName(r.Kind))
+		}
+	}
+
+	switch op {
+	case "==":
+		return Bool(lf == rf), nil
+	case "!=":
+		return Bool(lf != rf), nil
+	case "<":
+		return Bool(lf < rf), nil
+	case "<=":
+		return Bool(lf <= rf), nil
+	case ">":
+		return Bool(lf > rf), nil
+	case ">=":
+		return Bool(lf >= rf), nil
+	}
+	return Value{}, fmt.Errorf("jq: unsupported comparison operator %s", op)
+}
+
+func asString(v Value) string {
+	switch v.Kind {
+	case KindStr:
+		return v.Str
+	case KindNum:
+		return strconv.FormatFloat(v.Num, 'f', -1, 64)
+	case KindBool:
+		if v.Bool {
+			return "true"
+		}
+		return "false"
+	default:
+		return ""
+	}
+}
+
+func arithValues(op string, l, r Value) (Value, error) {
+	if op == "+" && (l.Kind == KindStr || r.Kind == KindStr) {
+		return Str(asString(l) + asString(r)), nil
+	}
+
+	lf, lok := asFloat(l)
+	rf, rok := asFloat(r)
+	if !lok || !rok {
+		return Value{}, fmt.Errorf("jq: cannot apply %s to %s and %s", op, kindName(l.Kind), kindName(r.Kind))
+	}
+
+	switch op {
+	case "+":
+		return Num(lf + rf), nil
+	case "-":
+		return Num(lf - rf), nil
+	case "*":
+		return Num(lf * rf), nil
+	case "/":
+		return Num(lf / rf), nil
+	}
+	return Value{}, fmt.Errorf("jq: unsupported arithmetic operator %s", op)
+}
+
+func binaryFilter(op string, left, right Filter, apply func(string, Value, Value) (Value, error)) Filter {
+	return func(in Value) ([]Value, error) {
+		lefts, err := left(in)
+		if err != nil {
+			return nil, err
+		}
+		rights, err := right(in)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]Value, 0, len(lefts)*len(rights))
+		for _, l := range lefts {
+			for _, r := range rights {
+				v, err := apply(op, l, r)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, v)
+			}
+		}
+		return out, nil
+	}
+}
+
+// numberLiteral parses a jq number token via strconv, same as the rest of
+// this codebase does for numeric literals.
+func numberLiteral(text string) (Value, error) {
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return Value{}, fmt.Errorf("jq: invalid number %q", text)
+	}
+	return Num(n), nil
+}
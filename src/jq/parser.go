@@ -0,0 +1,209 @@
+package jq
+
+import "fmt"
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) advance() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) atEnd() bool {
+	return p.peek().kind == tokEOF
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	if p.peek().kind != kind {
+		return token{}, fmt.Errorf("jq: expected %s, got %q", what, p.peek().text)
+	}
+	return p.advance(), nil
+}
+
+// parsePipe is the entry point: `a | b | c` applies a, then b to every
+// result, then c to every result of that, lowest precedence of all.
+func (p *parser) parsePipe() (Filter, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokPipe {
+		p.advance()
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = chain(left, right)
+	}
+	return left, nil
+}
+
+var comparisonOps = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+func (p *parser) parseComparison() (Filter, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind == tokOp && comparisonOps[p.peek().text] {
+		op := p.advance().text
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return binaryFilter(op, left, right, compareValues), nil
+	}
+	return left, nil
+}
+
+func (p *parser) parseAdditive() (Filter, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && (p.peek().text == "+" || p.peek().text == "-") {
+		op := p.advance().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryFilter(op, left, right, arithValues)
+	}
+	return left, nil
+}
+
+func (p *parser) parseMultiplicative() (Filter, error) {
+	left, err := p.parsePostfix()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOp && (p.peek().text == "*" || p.peek().text == "/") {
+		op := p.advance().text
+		right, err := p.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		left = binaryFilter(op, left, right, arithValues)
+	}
+	return left, nil
+}
+
+// parsePostfix parses a primary filter followed by any number of `.field`
+// or `[]` suffixes, chaining each onto the filter built so far.
+func (p *parser) parsePostfix() (Filter, error) {
+	f, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().kind {
+		case tokDot:
+			p.advance()
+			name, err := p.expect(tokIdent, "field name after '.'")
+			if err != nil {
+				return nil, err
+			}
+			f = chain(f, field(name.text))
+		case tokLBracket:
+			p.advance()
+			if _, err := p.expect(tokRBracket, "']' (indexing with an expression isn't supported yet)"); err != nil {
+				return nil, err
+			}
+			f = chain(f, iterate)
+		default:
+			return f, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (Filter, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokDot:
+		p.advance()
+		if p.peek().kind == tokIdent {
+			name := p.advance()
+			return field(name.text), nil
+		}
+		if p.peek().kind == tokLBracket {
+			p.advance()
+			if _, err := p.expect(tokRBracket, "']' (indexing with an expression isn't supported yet)"); err != nil {
+				return nil, err
+			}
+			return iterate, nil
+		}
+		return identity, nil
+	case tokNumber:
+		p.advance()
+		v, err := numberLiteral(t.text)
+		if err != nil {
+			return nil, err
+		}
+		return literal(v), nil
+	case tokString:
+		p.advance()
+		return literal(Str(t.text)), nil
+	case tokLParen:
+		p.advance()
+		inner, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.expect(tokRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	case tokIdent:
+		return p.parseIdentFilter()
+	default:
+		return nil, fmt.Errorf("jq: unexpected token %q", t.text)
+	}
+}
+
+func (p *parser) parseIdentFilter() (Filter, error) {
+	name := p.advance().text
+	switch name {
+	case "select":
+		inner, err := p.parseCallArg(name)
+		if err != nil {
+			return nil, err
+		}
+		return selectFilter(inner), nil
+	case "map":
+		inner, err := p.parseCallArg(name)
+		if err != nil {
+			return nil, err
+		}
+		return mapFilter(inner), nil
+	case "length":
+		return lengthFilter, nil
+	case "keys":
+		return keysFilter, nil
+	default:
+		return nil, fmt.Errorf("jq: unknown filter %q", name)
+	}
+}
+
+func (p *parser) parseCallArg(name string) (Filter, error) {
+	if _, err := p.expect(tokLParen, "'(' after "+name); err != nil {
+		return nil, err
+	}
+	inner, err := p.parsePipe()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(tokRParen, "')'"); err != nil {
+		return nil, err
+	}
+	return inner, nil
+}
@@ -0,0 +1,103 @@
+package lang
+
+import (
+	"github.com/alligator/jqawk/src/jq"
+)
+
+// toJQValue converts a jqawk Value into jq's own Value representation so a
+// compiled filter can run over it - see src/jq's package doc for why jq
+// doesn't just operate on *Value directly.
+func toJQValue(v *Value) jq.Value {
+	switch v.Tag {
+	case ValueStr:
+		return jq.Str(*v.Str)
+	case ValueNum:
+		return jq.Num(*v.Num)
+	case ValueBool:
+		return jq.Bool(*v.Bool)
+	case ValueArray:
+		items := make([]jq.Value, 0, len(v.Array))
+		for _, cell := range v.Array {
+			items = append(items, toJQValue(&cell.Value))
+		}
+		return jq.Array(items)
+	case ValueObj:
+		vals := make(map[string]jq.Value, len(*v.Obj))
+		for k, cell := range *v.Obj {
+			vals[k] = toJQValue(&cell.Value)
+		}
+		return jq.Object(v.ObjKeys, vals)
+	default:
+		return jq.Nil()
+	}
+}
+
+// fromJQValue converts a jq.Value produced by a filter back into a jqawk
+// Value, the inverse of toJQValue.
+func fromJQValue(v jq.Value) Value {
+	switch v.Kind {
+	case jq.KindStr:
+		return NewValue(v.Str)
+	case jq.KindNum:
+		return NewValue(v.Num)
+	case jq.KindBool:
+		return NewValue(v.Bool)
+	case jq.KindArray:
+		arr := NewArray()
+		for _, item := range v.Array {
+			converted := fromJQValue(item)
+			arr.Array = append(arr.Array, NewCell(converted))
+		}
+		return arr
+	case jq.KindObject:
+		obj := NewObject()
+		for _, k := range v.Keys {
+			converted := fromJQValue(v.Object[k])
+			(*obj.Obj)[k] = NewCell(converted)
+		}
+		obj.ObjKeys = append(obj.ObjKeys, v.Keys...)
+		return obj
+	default:
+		return NewValue(nil)
+	}
+}
+
+// nativeJq compiles its string argument as a jq filter (see src/jq) and
+// returns a callable value: applying it to a jqawk value runs the filter
+// and collects every value it produces into an array, e.g.
+// `jq(".items[] | select(.qty>0)")($)`.
+func nativeJq(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+	if err := checkArgCount(args, 1); err != nil {
+		return nil, err
+	}
+	srcArg, err := checkArg(args, 0, ValueStr)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err := jq.Compile(*srcArg.Str)
+	if err != nil {
+		return nil, err
+	}
+
+	applyFn := func(e *Evaluator, callArgs []*Value, this *Value) (*Value, error) {
+		if err := checkArgCount(callArgs, 1); err != nil {
+			return nil, err
+		}
+
+		results, err := filter(toJQValue(callArgs[0]))
+		if err != nil {
+			return nil, err
+		}
+
+		out := NewArray()
+		for _, r := range results {
+			converted := fromJQValue(r)
+			out.Array = append(out.Array, NewCell(converted))
+		}
+		return &out, nil
+	}
+
+	fnVal := Value{Tag: ValueNativeFn, NativeFn: applyFn}
+	return &fnVal, nil
+}
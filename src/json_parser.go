@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/big"
 )
 
 type jsonParser struct {
@@ -12,10 +13,35 @@ type jsonParser struct {
 
 func newJsonParser(reader io.Reader) jsonParser {
 	dec := json.NewDecoder(reader)
+	if BigNumMode {
+		dec.UseNumber()
+	}
 	return jsonParser{dec}
 }
 
-func (p *jsonParser) next() (Value, error) {
+// newBigNumValue builds a ValueNum from a json.Number, preserving the exact
+// integer via Value.BigInt when the literal is an integer (BigNumMode is
+// only used here when the decoder was put in UseNumber mode). Non-integer
+// literals (decimals, exponents) still go through float64 - arbitrary
+// precision for those isn't implemented.
+func newBigNumValue(num json.Number) (Value, error) {
+	s := num.String()
+	if i, ok := new(big.Int).SetString(s, 10); ok {
+		f, err := num.Float64()
+		if err != nil {
+			return Value{}, err
+		}
+		return NewBigIntValue(i, f), nil
+	}
+
+	f, err := num.Float64()
+	if err != nil {
+		return Value{}, err
+	}
+	return NewValue(f), nil
+}
+
+func (p *jsonParser) Next() (Value, error) {
 	tok, err := p.dec.Token()
 	if err != nil {
 		return Value{}, err
@@ -38,11 +64,7 @@ func (p *jsonParser) next() (Value, error) {
 	case float64:
 		return NewValue(v), nil
 	case json.Number:
-		f, err := v.Float64()
-		if err != nil {
-			return Value{}, err
-		}
-		return NewValue(f), nil
+		return newBigNumValue(v)
 	case nil:
 		return NewValue(nil), nil
 	default:
@@ -64,7 +86,7 @@ func (p *jsonParser) parseObject() (Value, error) {
 			return Value{}, fmt.Errorf("unexpected string key, got %T", ktok)
 		}
 
-		val, err := p.next()
+		val, err := p.Next()
 		if err != nil {
 			return Value{}, err
 		}
@@ -84,7 +106,7 @@ func (p *jsonParser) parseArray() (Value, error) {
 	array := NewArray()
 
 	for p.dec.More() {
-		val, err := p.next()
+		val, err := p.Next()
 		if err != nil {
 			return Value{}, err
 		}
@@ -0,0 +1,183 @@
+package jsonpath
+
+import "fmt"
+
+// Eval walks root applying each of the path's segments in turn, returning
+// every value it matches, in document order. filter is consulted for `?()`
+// predicates; pass nil if the path has none.
+func (p *Path) Eval(root Value, filter FilterEval) ([]Value, error) {
+	current := []Value{root}
+
+	for _, o := range p.ops {
+		candidates := current
+		if o.deepScan {
+			candidates = nil
+			for _, v := range current {
+				candidates = append(candidates, descendantsAndSelf(v)...)
+			}
+		}
+
+		var next []Value
+		for _, v := range candidates {
+			matched, err := applyOp(o, v, filter)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, matched...)
+		}
+		current = next
+	}
+
+	return current, nil
+}
+
+// descendantsAndSelf returns v followed by every value nested inside it, in
+// preorder - the node set a `..` segment's selector is matched against.
+func descendantsAndSelf(v Value) []Value {
+	out := []Value{v}
+	switch v.Kind {
+	case KindArray:
+		for _, item := range v.Array {
+			out = append(out, descendantsAndSelf(item)...)
+		}
+	case KindObject:
+		for _, k := range v.Keys {
+			out = append(out, descendantsAndSelf(v.Object[k])...)
+		}
+	}
+	return out
+}
+
+func applyOp(o op, v Value, filter FilterEval) ([]Value, error) {
+	switch o.kind {
+	case opName:
+		if v.Kind != KindObject {
+			return nil, nil
+		}
+		if child, ok := v.Object[o.name]; ok {
+			return []Value{child}, nil
+		}
+		return nil, nil
+	case opWildcard:
+		return children(v), nil
+	case opIndex:
+		return indexArray(v, o.index), nil
+	case opSlice:
+		return sliceArray(v, o.start, o.end, o.step), nil
+	case opFilter:
+		return applyFilter(v, o.filterSrc, filter)
+	default:
+		return nil, fmt.Errorf("jsonpath: unknown segment kind %d", o.kind)
+	}
+}
+
+// children returns v's direct children in order: elements for an array,
+// values (in key order) for an object, nothing for a scalar.
+func children(v Value) []Value {
+	switch v.Kind {
+	case KindArray:
+		return append([]Value(nil), v.Array...)
+	case KindObject:
+		out := make([]Value, 0, len(v.Keys))
+		for _, k := range v.Keys {
+			out = append(out, v.Object[k])
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func indexArray(v Value, index int) []Value {
+	if v.Kind != KindArray {
+		return nil
+	}
+	i := index
+	if i < 0 {
+		i += len(v.Array)
+	}
+	if i < 0 || i >= len(v.Array) {
+		return nil
+	}
+	return []Value{v.Array[i]}
+}
+
+// sliceArray implements Python-ish slicing: start/end/step may each be
+// omitted (nil), negative indices count from the end, and a negative step
+// walks backwards.
+func sliceArray(v Value, start, end, step *int) []Value {
+	if v.Kind != KindArray {
+		return nil
+	}
+	n := len(v.Array)
+
+	s := 1
+	if step != nil {
+		s = *step
+	}
+	if s == 0 {
+		return nil
+	}
+
+	lo, hi := 0, n
+	if s < 0 {
+		lo, hi = n-1, -1
+	}
+	if start != nil {
+		lo = normalizeIndex(*start, n)
+	}
+	if end != nil {
+		hi = normalizeIndex(*end, n)
+	}
+
+	var out []Value
+	if s > 0 {
+		for i := lo; i < hi && i < n; i += s {
+			if i >= 0 {
+				out = append(out, v.Array[i])
+			}
+		}
+	} else {
+		for i := lo; i > hi && i >= 0; i += s {
+			if i < n {
+				out = append(out, v.Array[i])
+			}
+		}
+	}
+	return out
+}
+
+func normalizeIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	if i < 0 {
+		i = 0
+	}
+	if i > n {
+		i = n
+	}
+	return i
+}
+
+// applyFilter selects the children of v (its array elements, or object
+// values) for which filter(filterSrc, child) holds, the usual JSONPath
+// `[?(...)]` semantics of filtering a node list rather than testing v
+// itself.
+func applyFilter(v Value, filterSrc string, filter FilterEval) ([]Value, error) {
+	if filter == nil {
+		return nil, fmt.Errorf("jsonpath: filter predicate %q used with no evaluator configured", filterSrc)
+	}
+
+	var out []Value
+	for _, item := range children(v) {
+		ok, err := filter(filterSrc, item)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, item)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,96 @@
+// Package jsonpath implements a subset of JSONPath - name, wildcard (`*`),
+// deep scan (`..`), index, slice (`[start:end:step]`) and filter
+// (`[?(<expr>)]`) selectors - for the `-P` root-selector flag described in
+// the JSONPath root-selector request.
+//
+// Like src/jq, jsonpath operates on its own Value type rather than
+// lang.Value so this package carries no dependency on src (which needs to
+// depend on jsonpath to expose `-P`); see lang's toJSONPathValue /
+// fromJSONPathValue for the adapter. Filter predicates are deliberately not
+// a bundled expression language: they're handed to the caller's FilterEval
+// as raw source, which is how lang runs them as ordinary jqawk expressions
+// with `@` bound to the candidate node.
+//
+// Not supported: union selectors (`[a,b]`), negative steps combined with
+// open-ended slices in every edge case RFC 9535 defines, and script
+// selectors outside of `?()`. Parse reports an error for anything outside
+// this subset rather than silently matching the wrong nodes.
+package jsonpath
+
+// Kind identifies the shape of a Value, mirroring lang.ValueTag closely
+// enough that the adapter in lang/rootpath.go is a straight switch-to-switch
+// copy.
+type Kind int
+
+const (
+	KindNil Kind = iota
+	KindStr
+	KindNum
+	KindBool
+	KindArray
+	KindObject
+)
+
+// Value is jsonpath's own boxed value - deliberately independent of
+// lang.Value, see the package doc comment for why.
+type Value struct {
+	Kind   Kind
+	Str    string
+	Num    float64
+	Bool   bool
+	Array  []Value
+	Object map[string]Value
+	Keys   []string // preserves object insertion order, like lang.Value.ObjKeys
+}
+
+func Nil() Value          { return Value{Kind: KindNil} }
+func Str(s string) Value  { return Value{Kind: KindStr, Str: s} }
+func Num(n float64) Value { return Value{Kind: KindNum, Num: n} }
+func Bool(b bool) Value   { return Value{Kind: KindBool, Bool: b} }
+func Array(vs []Value) Value {
+	return Value{Kind: KindArray, Array: vs}
+}
+
+// Object builds an object value from keys/vals in order, the same
+// ordered-keys-plus-map shape lang.Value keeps in ObjKeys/Obj.
+func Object(keys []string, vals map[string]Value) Value {
+	return Value{Kind: KindObject, Keys: keys, Object: vals}
+}
+
+// opKind identifies one path segment's selector.
+type opKind int
+
+const (
+	opName opKind = iota
+	opWildcard
+	opIndex
+	opSlice
+	opFilter
+)
+
+// op is one compiled path segment, e.g. `.book`, `[*]`, `[0:10:2]` or
+// `[?(@.price<10)]`. deepScan marks a `..` prefix: the segment applies to
+// every descendant of the current node set (itself included), not just its
+// direct children.
+type op struct {
+	kind     opKind
+	deepScan bool
+
+	name  string // opName
+	index int    // opIndex
+
+	start, end, step *int // opSlice; nil means "unspecified"
+
+	filterSrc string // opFilter: the raw `@...` predicate source
+}
+
+// Path is a compiled JSONPath expression, built by Parse.
+type Path struct {
+	ops []op
+}
+
+// FilterEval evaluates a bracket predicate's raw source (e.g. "@.price<10")
+// against a candidate node and reports whether it holds. Parse has no
+// opinion on what language that source is in - lang supplies this by
+// running it as a jqawk expression with `@` bound to node.
+type FilterEval func(src string, node Value) (bool, error)
@@ -0,0 +1,238 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Parse compiles src (e.g. "$.store.book[*]", "$..author",
+// "$.items[0:10]", "$.data[?(@.price<10)]") into a *Path ready to Eval. A
+// leading "$" is optional and, if present, simply skipped - every path is
+// relative to whatever root Eval is given.
+func Parse(src string) (*Path, error) {
+	p := &parser{src: src}
+	p.skipRoot()
+
+	var ops []op
+	for !p.atEnd() {
+		o, err := p.parseSegment()
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, o)
+	}
+	return &Path{ops: ops}, nil
+}
+
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) atEnd() bool { return p.pos >= len(p.src) }
+
+func (p *parser) skipRoot() {
+	if !p.atEnd() && p.src[p.pos] == '$' {
+		p.pos++
+	}
+}
+
+func (p *parser) errf(format string, args ...interface{}) error {
+	return fmt.Errorf("jsonpath: "+format+" (at %q)", append(args, p.src[p.pos:])...)
+}
+
+// parseSegment consumes one `.name`, `..name`, `.*`, `..*` or bracketed
+// segment starting at p.pos.
+func (p *parser) parseSegment() (op, error) {
+	deepScan := false
+
+	if !p.atEnd() && p.src[p.pos] == '.' {
+		p.pos++
+		if !p.atEnd() && p.src[p.pos] == '.' {
+			deepScan = true
+			p.pos++
+		}
+	}
+
+	if p.atEnd() {
+		return op{}, p.errf("unexpected end of path")
+	}
+
+	switch p.src[p.pos] {
+	case '*':
+		p.pos++
+		return op{kind: opWildcard, deepScan: deepScan}, nil
+	case '[':
+		return p.parseBracket(deepScan)
+	default:
+		name := p.parseIdent()
+		if name == "" {
+			return op{}, p.errf("expected a name")
+		}
+		return op{kind: opName, name: name, deepScan: deepScan}, nil
+	}
+}
+
+func (p *parser) parseIdent() string {
+	start := p.pos
+	for !p.atEnd() && p.src[p.pos] != '.' && p.src[p.pos] != '[' {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *parser) parseBracket(deepScan bool) (op, error) {
+	p.pos++ // consume '['
+	if p.atEnd() {
+		return op{}, p.errf("unterminated '['")
+	}
+
+	switch p.src[p.pos] {
+	case '*':
+		p.pos++
+		if err := p.expect(']'); err != nil {
+			return op{}, err
+		}
+		return op{kind: opWildcard, deepScan: deepScan}, nil
+	case '\'', '"':
+		name, err := p.parseQuoted()
+		if err != nil {
+			return op{}, err
+		}
+		if err := p.expect(']'); err != nil {
+			return op{}, err
+		}
+		return op{kind: opName, name: name, deepScan: deepScan}, nil
+	case '?':
+		filterSrc, err := p.parseFilter()
+		if err != nil {
+			return op{}, err
+		}
+		return op{kind: opFilter, filterSrc: filterSrc, deepScan: deepScan}, nil
+	default:
+		return p.parseIndexOrSlice(deepScan)
+	}
+}
+
+func (p *parser) expect(c byte) error {
+	if p.atEnd() || p.src[p.pos] != c {
+		return p.errf("expected %q", string(c))
+	}
+	p.pos++
+	return nil
+}
+
+func (p *parser) parseQuoted() (string, error) {
+	quote := p.src[p.pos]
+	p.pos++
+	start := p.pos
+	for !p.atEnd() && p.src[p.pos] != quote {
+		p.pos++
+	}
+	if p.atEnd() {
+		return "", p.errf("unterminated quoted name")
+	}
+	name := p.src[start:p.pos]
+	p.pos++ // consume closing quote
+	return name, nil
+}
+
+// parseFilter parses `?(<expr>)]`, tracking paren depth so an expr
+// containing its own parens (e.g. `@.price < (10)`) doesn't truncate early.
+func (p *parser) parseFilter() (string, error) {
+	p.pos++ // consume '?'
+	if err := p.expect('('); err != nil {
+		return "", err
+	}
+
+	start := p.pos
+	depth := 1
+	for !p.atEnd() && depth > 0 {
+		switch p.src[p.pos] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		}
+		if depth > 0 {
+			p.pos++
+		}
+	}
+	if depth != 0 {
+		return "", p.errf("unterminated filter predicate")
+	}
+
+	exprSrc := p.src[start:p.pos]
+	p.pos++ // consume ')'
+	if err := p.expect(']'); err != nil {
+		return "", err
+	}
+	return exprSrc, nil
+}
+
+// parseIndexOrSlice parses `[N]`, `[start:end]` or `[start:end:step]`; any
+// part of a slice may be omitted (e.g. `[:10]`, `[::2]`).
+func (p *parser) parseIndexOrSlice(deepScan bool) (op, error) {
+	var parts []*int
+	for {
+		n, hasNum, err := p.parseSignedInt()
+		if err != nil {
+			return op{}, err
+		}
+		if hasNum {
+			parts = append(parts, &n)
+		} else {
+			parts = append(parts, nil)
+		}
+
+		if !p.atEnd() && p.src[p.pos] == ':' {
+			p.pos++
+			continue
+		}
+		break
+	}
+
+	if err := p.expect(']'); err != nil {
+		return op{}, err
+	}
+
+	if len(parts) == 1 {
+		if parts[0] == nil {
+			return op{}, p.errf("expected an index")
+		}
+		return op{kind: opIndex, index: *parts[0], deepScan: deepScan}, nil
+	}
+	if len(parts) > 3 {
+		return op{}, p.errf("too many ':' in slice")
+	}
+
+	o := op{kind: opSlice, deepScan: deepScan}
+	o.start = parts[0]
+	if len(parts) > 1 {
+		o.end = parts[1]
+	}
+	if len(parts) > 2 {
+		o.step = parts[2]
+	}
+	return o, nil
+}
+
+func (p *parser) parseSignedInt() (int, bool, error) {
+	start := p.pos
+	if !p.atEnd() && (p.src[p.pos] == '-' || p.src[p.pos] == '+') {
+		p.pos++
+	}
+	digitsStart := p.pos
+	for !p.atEnd() && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+		p.pos++
+	}
+	if p.pos == digitsStart {
+		p.pos = start
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(p.src[start:p.pos])
+	if err != nil {
+		return 0, false, p.errf("invalid integer %q", p.src[start:p.pos])
+	}
+	return n, true, nil
+}
@@ -0,0 +1,58 @@
+package lang
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+// jsonSeqRS is the RFC 7464 JSON Text Sequence record separator: each
+// record is a 0x1E byte, a JSON text, and a trailing newline.
+const jsonSeqRS = 0x1E
+
+// jsonSeqDecoder reads RFC 7464 JSON Text Sequences, one record per Next()
+// call - the same shape as ndjsonDecoder, just split on 0x1E instead of a
+// newline.
+type jsonSeqDecoder struct {
+	scanner *bufio.Scanner
+}
+
+func newJsonSeqDecoder(r io.Reader) *jsonSeqDecoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	scanner.Split(splitJsonSeqRecords)
+	return &jsonSeqDecoder{scanner}
+}
+
+// splitJsonSeqRecords is a bufio.SplitFunc that breaks a JSON Text Sequence
+// stream on 0x1E, mirroring bufio.ScanLines but for the record separator
+// instead of '\n'. The text leading the very first separator is always
+// empty for a well-formed stream and is skipped by Next like a blank line.
+func splitJsonSeqRecords(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, jsonSeqRS); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func (d *jsonSeqDecoder) Next() (Value, error) {
+	for d.scanner.Scan() {
+		text := strings.TrimSpace(d.scanner.Text())
+		if text == "" {
+			continue
+		}
+		jp := newJsonParser(strings.NewReader(text))
+		return jp.Next()
+	}
+	if err := d.scanner.Err(); err != nil {
+		return Value{}, err
+	}
+	return Value{}, io.EOF
+}
@@ -67,12 +67,30 @@ const (
 	PlusPlus      // ++
 	MinusMinus    // --
 	Percent       // %
+	Import
+	Null
+	Is
+	Pipe         // |
+	Ellipsis     // ...
+	PercentEqual // %=
+	Include
+	Do
+	Backslash // \
+	Question  // ?
+	Exit
+	BeginFile
+	EndFile
 )
 
 type Token struct {
 	Tag TokenTag
 	Pos int
 	Len int
+	// Flags holds a regex literal's trailing flag letters (e.g. "im" for
+	// /foo/im) and is empty for every other token. It's kept on the token
+	// rather than folded into Pos/Len since it isn't source text the
+	// FileSet can resolve a lexeme for.
+	Flags string
 }
 
 type Lexer struct {
@@ -80,14 +98,30 @@ type Lexer struct {
 	pos        int
 	line       int
 	tokenStart int
+	file       *File
+	fset       *FileSet
 }
 
 func NewLexer(src string) Lexer {
+	fset := NewFileSet()
+	file := fset.AddFile("<program>", src)
+	return NewLexerInFile(fset, file)
+}
+
+// NewLexerInFile lexes file's source against an already-existing FileSet,
+// so its tokens' positions land in the same address space as every other
+// file registered there. ProgramLoader uses this to give an included file
+// its own Lexer that still shares the root file's FileSet (and so resolves
+// lexemes and diagnostics correctly no matter which lexer produced a
+// token - see FileSet.Lexeme).
+func NewLexerInFile(fset *FileSet, file *File) Lexer {
 	return Lexer{
-		src:        src,
+		src:        file.Src,
 		pos:        0,
 		line:       1,
 		tokenStart: 0,
+		file:       file,
+		fset:       fset,
 	}
 }
 
@@ -95,23 +129,34 @@ func (l *Lexer) atEnd() bool {
 	return l.pos >= len(l.src)
 }
 
+// globalPos converts a byte offset local to l.src into a Pos in l.fset's
+// shared address space, so tokens remain traceable to their file even when
+// several lexers share one FileSet.
+func (l *Lexer) globalPos(local int) int {
+	return l.file.Base + local
+}
+
 func (l *Lexer) simpleToken(tag TokenTag) Token {
-	return Token{tag, l.tokenStart, 0}
+	return Token{tag, l.globalPos(l.tokenStart), 0, ""}
 }
 
 func (l *Lexer) errorToken() Token {
-	return Token{Error, l.tokenStart, 0}
+	return Token{Error, l.globalPos(l.tokenStart), 0, ""}
 }
 
 func (l *Lexer) stringToken(tag TokenTag, length int) Token {
-	return Token{tag, l.tokenStart, length}
+	return Token{tag, l.globalPos(l.tokenStart), length, ""}
 }
 
 func (l *Lexer) advance() byte {
 	if !l.atEnd() {
 		l.pos++
 	}
-	return l.src[l.pos-1]
+	c := l.src[l.pos-1]
+	if c == '\n' {
+		l.file.AddLine(l.pos)
+	}
+	return c
 }
 
 func (l *Lexer) peek() byte {
@@ -121,6 +166,13 @@ func (l *Lexer) peek() byte {
 	return l.src[l.pos]
 }
 
+func (l *Lexer) peekNext() byte {
+	if l.pos+1 >= len(l.src) {
+		return 0
+	}
+	return l.src[l.pos+1]
+}
+
 func (l *Lexer) skipWhitespace() {
 	for !l.atEnd() {
 		switch l.peek() {
@@ -151,6 +203,10 @@ func (l *Lexer) identifier() Token {
 		return l.simpleToken(Begin)
 	case "END":
 		return l.simpleToken(End)
+	case "BEGINFILE":
+		return l.simpleToken(BeginFile)
+	case "ENDFILE":
+		return l.simpleToken(EndFile)
 	case "print":
 		return l.simpleToken(Print)
 	case "$":
@@ -167,6 +223,8 @@ func (l *Lexer) identifier() Token {
 		return l.simpleToken(For)
 	case "while":
 		return l.simpleToken(While)
+	case "do":
+		return l.simpleToken(Do)
 	case "in":
 		return l.simpleToken(In)
 	case "match":
@@ -181,20 +239,52 @@ func (l *Lexer) identifier() Token {
 		return l.simpleToken(Continue)
 	case "next":
 		return l.simpleToken(Next)
+	case "exit":
+		return l.simpleToken(Exit)
+	case "import":
+		return l.simpleToken(Import)
+	case "include":
+		return l.simpleToken(Include)
+	case "null":
+		return l.simpleToken(Null)
+	case "is":
+		return l.simpleToken(Is)
 	default:
 		return l.stringToken(Ident, l.pos-l.tokenStart)
 	}
 }
 
 func (l *Lexer) number() Token {
-	for !l.atEnd() {
-		r := rune(l.peek())
-		if unicode.IsDigit(r) {
+	for !l.atEnd() && unicode.IsDigit(rune(l.peek())) {
+		l.advance()
+	}
+
+	// a '.' only belongs to this number if it's followed by another digit -
+	// otherwise it's a member-access dot on an integer literal, e.g.
+	// `(1).toString()`.
+	if l.peek() == '.' && unicode.IsDigit(rune(l.peekNext())) {
+		l.advance()
+		for !l.atEnd() && unicode.IsDigit(rune(l.peek())) {
 			l.advance()
-		} else {
-			break
 		}
 	}
+
+	if l.peek() == 'e' || l.peek() == 'E' {
+		lookahead := l.pos + 1
+		if lookahead < len(l.src) && (l.src[lookahead] == '+' || l.src[lookahead] == '-') {
+			lookahead++
+		}
+		if lookahead < len(l.src) && unicode.IsDigit(rune(l.src[lookahead])) {
+			l.advance() // e/E
+			if l.peek() == '+' || l.peek() == '-' {
+				l.advance()
+			}
+			for !l.atEnd() && unicode.IsDigit(rune(l.peek())) {
+				l.advance()
+			}
+		}
+	}
+
 	return l.stringToken(Num, l.pos-l.tokenStart)
 }
 
@@ -203,59 +293,54 @@ func (l *Lexer) string(quoteChar byte) (Token, error) {
 		l.advance()
 	}
 	if l.atEnd() {
-		return l.errorToken(), l.error(l.tokenStart+1, "unexpected EOF while reading string")
+		return l.errorToken(), l.error(l.globalPos(l.tokenStart)+1, "unexpected EOF while reading string")
 	}
 	l.advance()
 	l.tokenStart++ // skip over the opening quote
 	return l.stringToken(Str, l.pos-l.tokenStart-1), nil
 }
 
-// the parser calls this when it finds a '/' in prefix position
-func (l *Lexer) regex() (Token, error) {
+// Regex is called when a '/' is found in prefix position, both by the
+// parser and (for debug/dump tooling outside the package) cli's lexer
+// dumper.
+func (l *Lexer) Regex() (Token, error) {
 	for !l.atEnd() && l.peek() != '/' {
 		l.advance()
 	}
 	if l.atEnd() {
-		return l.errorToken(), l.error(l.tokenStart, "unexpected EOF while reading regex")
+		return l.errorToken(), l.error(l.globalPos(l.tokenStart), "unexpected EOF while reading regex")
 	}
 	l.advance()
 	l.tokenStart++
-	return l.stringToken(Regex, l.pos-l.tokenStart-1), nil
+	pattern := l.pos - l.tokenStart - 1
+
+	flagsStart := l.pos
+	for !l.atEnd() && unicode.IsLetter(rune(l.peek())) {
+		l.advance()
+	}
+	flags := l.src[flagsStart:l.pos]
+
+	tok := l.stringToken(Regex, pattern)
+	tok.Flags = flags
+	return tok, nil
 }
 
-func (l *Lexer) GetString(token *Token) string {
-	return l.src[token.Pos : token.Pos+token.Len]
+// FileSet returns the FileSet backing this lexer's positions, so callers
+// outside the package (cli's debug output, most notably) can resolve a
+// Token's file/line/col or lexeme without needing a live Lexer around.
+func (l *Lexer) FileSet() *FileSet {
+	return l.fset
 }
 
-func (l *Lexer) GetLineAndCol(pos int) (string, int, int) {
-	line := 1
-	col := 1
-	lineStart := 0
-	inLine := false
-	for i, r := range l.src {
-		if r == '\n' {
-			if inLine {
-				return l.src[lineStart:i], line, col
-			}
-			line++
-			lineStart = i + 1
-		}
-		if i == pos {
-			inLine = true
-			col = i - lineStart
-		}
-	}
-	return l.src[lineStart:], line, col
+// GetString resolves token's source text through l's FileSet rather than
+// l.src directly, so it still works for a token produced by a different
+// file's Lexer in a multi-file (ProgramLoader-merged) Program.
+func (l *Lexer) GetString(token *Token) string {
+	return l.fset.Lexeme(*token)
 }
 
 func (l *Lexer) error(pos int, msg string) SyntaxError {
-	srcLine, line, col := l.GetLineAndCol(pos)
-	return SyntaxError{
-		Message: msg,
-		Line:    line,
-		Col:     col,
-		SrcLine: srcLine,
-	}
+	return newSyntaxError(l.fset, pos, msg)
 }
 
 func (l *Lexer) Next() (Token, error) {
@@ -270,6 +355,7 @@ func (l *Lexer) Next() (Token, error) {
 
 	if c == '\n' {
 		l.pos++
+		l.file.AddLine(l.pos)
 		return l.simpleToken(Newline), nil
 	}
 
@@ -304,6 +390,11 @@ func (l *Lexer) Next() (Token, error) {
 	case ',':
 		return l.simpleToken(Comma), nil
 	case '.':
+		if l.peek() == '.' && l.peekNext() == '.' {
+			l.advance()
+			l.advance()
+			return l.simpleToken(Ellipsis), nil
+		}
 		return l.simpleToken(Dot), nil
 	case ';':
 		return l.simpleToken(SemiColon), nil
@@ -312,6 +403,10 @@ func (l *Lexer) Next() (Token, error) {
 	case '~':
 		return l.simpleToken(Tilde), nil
 	case '%':
+		if l.peek() == '=' {
+			l.advance()
+			return l.simpleToken(PercentEqual), nil
+		}
 		return l.simpleToken(Percent), nil
 	case '<':
 		if l.peek() == '=' {
@@ -391,8 +486,13 @@ func (l *Lexer) Next() (Token, error) {
 			l.advance()
 			return l.simpleToken(PipePipe), nil
 		}
+		return l.simpleToken(Pipe), nil
+	case '\\':
+		return l.simpleToken(Backslash), nil
+	case '?':
+		return l.simpleToken(Question), nil
 	case '\'', '"':
 		return l.string(c)
 	}
-	return l.errorToken(), l.error(l.pos-1, fmt.Sprintf("unexpected character %q", c))
+	return l.errorToken(), l.error(l.globalPos(l.pos-1), fmt.Sprintf("unexpected character %q", c))
 }
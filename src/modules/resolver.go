@@ -0,0 +1,81 @@
+// Package modules locates the source file behind a jqawk `import "path"`
+// statement. It deliberately knows nothing about lang.Value or how a module
+// is evaluated - that stays in package lang (see src/imports.go), the same
+// split src/compiler and src/jq use to keep this package dependency-free.
+package modules
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ModuleResolver resolves an import path to a file on disk. Resolver is the
+// only implementation today; the interface exists so alternate strategies
+// (e.g. an embedded registry) can stand in for it later without touching
+// callers.
+type ModuleResolver interface {
+	ResolveFile(path string) (string, error)
+}
+
+var _ ModuleResolver = (*Resolver)(nil)
+
+// Resolver turns an import path into a file on disk, searching SearchPaths
+// in order and falling back to the current directory.
+type Resolver struct {
+	SearchPaths []string
+}
+
+// NewResolver builds a Resolver from explicit search paths (`-I`, in order)
+// followed by the colon-separated JQAWK_PATH environment variable, matching
+// how AWKPATH/NODE_PATH-style search lists are normally assembled.
+func NewResolver(searchPaths []string) *Resolver {
+	paths := append([]string(nil), searchPaths...)
+	if envPath := os.Getenv("JQAWK_PATH"); envPath != "" {
+		paths = append(paths, filepath.SplitList(envPath)...)
+	}
+	return &Resolver{SearchPaths: paths}
+}
+
+// ResolveFile finds the .jqawk source file for path, trying it verbatim and
+// with a .jqawk extension appended, first relative to the current directory
+// and then under each search path.
+func (r *Resolver) ResolveFile(path string) (string, error) {
+	candidates := candidateNames(path)
+
+	for _, candidate := range candidates {
+		if fileExists(candidate) {
+			return candidate, nil
+		}
+	}
+
+	for _, dir := range r.SearchPaths {
+		for _, candidate := range candidates {
+			full := filepath.Join(dir, candidate)
+			if fileExists(full) {
+				return full, nil
+			}
+		}
+	}
+
+	return "", fmt.Errorf("could not find module %q (looked in %s and %d search path(s))", path, ".", len(r.SearchPaths))
+}
+
+func candidateNames(path string) []string {
+	if filepath.Ext(path) == ".jqawk" {
+		return []string{path}
+	}
+	return []string{path, path + ".jqawk"}
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// DefaultName derives the binding name for an import that didn't give one
+// explicitly, e.g. "lib/strutil" -> "strutil".
+func DefaultName(path string) string {
+	base := filepath.Base(path)
+	return base[:len(base)-len(filepath.Ext(base))]
+}
@@ -14,6 +14,21 @@ type Parser struct {
 	didEndStatement bool
 	inFunction      bool
 	inLoop          bool
+	// expected accumulates every token kind the parser peeked for and
+	// rejected since the last successful advance, so a failure can report
+	// "expected one of { ... }" instead of just whichever kind was checked
+	// last. See noteExpected/expectedError.
+	expected map[TokenTag]struct{}
+	// peeked holds a token already read from the lexer but not yet
+	// consumed by advance - see peekNext, used to look one token past
+	// p.current (e.g. to tell a loop label's ":" apart from anything else
+	// an identifier could start).
+	peeked *Token
+	// activeLabels is the stack of loop labels lexically enclosing p.current,
+	// outermost first. A labeled break/continue is resolved against it at
+	// parse time (see labelTarget) instead of surfacing as a runtime error
+	// the first time the loop actually runs.
+	activeLabels []string
 }
 
 type parseRule struct {
@@ -27,6 +42,7 @@ type Precedence uint8
 const (
 	PrecNone Precedence = iota
 	PrecAssign
+	PrecTernary
 	PrecLogical
 	PrecComparison
 	PrecAddition
@@ -41,6 +57,7 @@ func NewParser(l *Lexer) Parser {
 	p := Parser{
 		lexer:           l,
 		didEndStatement: false,
+		expected:        make(map[TokenTag]struct{}),
 	}
 	p.rules = map[TokenTag]parseRule{
 		Str:           {PrecNone, literal, nil},
@@ -70,6 +87,7 @@ func NewParser(l *Lexer) Parser {
 		MinusEqual:    {PrecAssign, nil, binary},
 		MultiplyEqual: {PrecAssign, nil, binary},
 		DivideEqual:   {PrecAssign, nil, binary},
+		PercentEqual:  {PrecAssign, nil, binary},
 		AmpAmp:        {PrecLogical, nil, binary},
 		PipePipe:      {PrecLogical, nil, binary},
 		Match:         {PrecNone, match, nil},
@@ -80,6 +98,10 @@ func NewParser(l *Lexer) Parser {
 		Percent:       {PrecMultiplication, nil, binary},
 		Is:            {PrecComparison, nil, is},
 		Function:      {PrecNone, function, nil},
+		Pipe:          {PrecLogical, nil, binary},
+		Ellipsis:      {PrecNone, restPattern, nil},
+		Backslash:     {PrecNone, boxedOperator, nil},
+		Question:      {PrecTernary, nil, ternary},
 	}
 	return p
 }
@@ -97,23 +119,42 @@ func (p *Parser) atEnd() bool {
 }
 
 func (p *Parser) error(pos int, msg string) SyntaxError {
-	srcLine, line, col := p.lexer.GetLineAndCol(pos)
-	return SyntaxError{
-		Message: msg,
-		Line:    line,
-		Col:     col,
-		SrcLine: srcLine,
+	return newSyntaxError(p.lexer.fset, pos, msg)
+}
+
+// rawNext returns the next token from p.peeked if peekNext already read one,
+// otherwise lexes a fresh one.
+func (p *Parser) rawNext() (Token, error) {
+	if p.peeked != nil {
+		t := *p.peeked
+		p.peeked = nil
+		return t, nil
+	}
+	return p.lexer.Next()
+}
+
+// peekNext looks one token past p.current without consuming it, caching the
+// result so the next advance (or peekNext) doesn't re-lex it.
+func (p *Parser) peekNext() (Token, error) {
+	if p.peeked == nil {
+		t, err := p.lexer.Next()
+		if err != nil {
+			return t, err
+		}
+		p.peeked = &t
 	}
+	return *p.peeked, nil
 }
 
 func (p *Parser) advance() (Token, error) {
-	t, err := p.lexer.Next()
+	t, err := p.rawNext()
 	if err != nil {
 		return t, err
 	}
 	p.previous = p.current
 	p.current = &t
 	p.didEndStatement = false
+	p.clearExpected()
 
 	if t.Tag == Newline {
 		// pretend the newline didn't exist and set didEndStatement
@@ -127,6 +168,66 @@ func (p *Parser) advance() (Token, error) {
 	return t, nil
 }
 
+// noteExpected records token kinds the parser looked for and didn't find at
+// the current position, so a later failure can report every kind that would
+// have been accepted here rather than just whichever was checked last.
+func (p *Parser) noteExpected(tags ...TokenTag) {
+	for _, tag := range tags {
+		p.expected[tag] = struct{}{}
+	}
+}
+
+// clearExpected drops the accumulated expected-token set. Called on every
+// successful advance, since it only describes what failed since the last
+// token the parser actually consumed.
+func (p *Parser) clearExpected() {
+	for tag := range p.expected {
+		delete(p.expected, tag)
+	}
+}
+
+// expectedError formats the token kinds accumulated in p.expected as
+// "expected one of { ... } but found <tok>". If nothing was recorded (a
+// position with no valid continuation at all, rather than a specific set of
+// tokens that didn't match), it falls back to a plain "unexpected token".
+func (p *Parser) expectedError() error {
+	if len(p.expected) == 0 {
+		return p.eofAwareError(p.current.Pos, fmt.Sprintf("unexpected token %s", p.current.Tag))
+	}
+
+	tags := make([]TokenTag, 0, len(p.expected))
+	for tag := range p.expected {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+
+	var sb strings.Builder
+	sb.WriteString("expected one of { ")
+	for i, tag := range tags {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(tag.String())
+	}
+	sb.WriteString(" } but found ")
+	sb.WriteString(p.current.Tag.String())
+
+	return p.eofAwareError(p.current.Pos, sb.String())
+}
+
+// eofAwareError is p.error with UnexpectedEOF set whenever the current token
+// is EOF - expectedError's two call sites both mean "some token was
+// required here and none was found", which at EOF means more input would
+// resolve it, unlike an error() call elsewhere that just happens to land on
+// the last token (e.g. "can only break inside a loop").
+func (p *Parser) eofAwareError(pos int, msg string) SyntaxError {
+	err := p.error(pos, msg)
+	if p.current.Tag == EOF {
+		err.UnexpectedEOF = true
+	}
+	return err
+}
+
 func (p *Parser) consume(tags ...TokenTag) error {
 	match := false
 	for _, tag := range tags {
@@ -137,18 +238,8 @@ func (p *Parser) consume(tags ...TokenTag) error {
 	}
 
 	if !match {
-		if len(tags) == 1 {
-			return p.error(p.current.Pos, fmt.Sprintf("expected %s", tags[0]))
-		}
-
-		var sb strings.Builder
-		for index, tag := range tags {
-			if index > 0 {
-				sb.WriteString(", ")
-			}
-			sb.WriteString(tag.String())
-		}
-		return p.error(p.current.Pos, fmt.Sprintf("expected one of %s", sb.String()))
+		p.noteExpected(tags...)
+		return p.expectedError()
 	}
 
 	_, err := p.advance()
@@ -160,13 +251,13 @@ func (p *Parser) block() (StatementBlock, error) {
 		return StatementBlock{}, err
 	}
 	startToken := *p.previous
-	errors := make([]error, 0)
+	var errList ErrorList
 
 	block := make([]Statement, 0)
 	for !p.atEnd() && p.current.Tag != RCurly {
 		statement, err := p.statement()
 		if err != nil {
-			errors = append(errors, err)
+			errList.Add(err)
 			if err2 := p.findNextStatement(); err2 != nil {
 				return StatementBlock{}, err2
 			}
@@ -175,7 +266,9 @@ func (p *Parser) block() (StatementBlock, error) {
 		}
 
 		if !p.atStatementEnd() {
-			errors = append(errors, p.error(p.current.Pos, "unexpected end of input"))
+			eofErr := p.error(p.current.Pos, "unexpected end of input")
+			eofErr.UnexpectedEOF = true
+			errList.Add(eofErr)
 		}
 	}
 	if err := p.consume(RCurly); err != nil {
@@ -183,15 +276,71 @@ func (p *Parser) block() (StatementBlock, error) {
 	}
 	p.didEndStatement = true
 
-	if len(errors) > 0 {
-		return StatementBlock{}, ErrorGroup{errors}
+	if err := errList.Err(); err != nil {
+		return StatementBlock{}, err
 	}
 
 	return StatementBlock{startToken, block}, nil
 }
 
+// label parses an optional `name:` prefix in front of a for/while/do loop, so
+// a break/continue elsewhere can target this loop specifically instead of
+// its own innermost one. It returns "" if p.current isn't the start of one.
+func (p *Parser) label() (string, error) {
+	if p.current.Tag != Ident {
+		return "", nil
+	}
+	next, err := p.peekNext()
+	if err != nil || next.Tag != Colon {
+		return "", nil
+	}
+
+	label := p.lexer.GetString(p.current)
+	if _, err := p.advance(); err != nil { // the label identifier
+		return "", err
+	}
+	if err := p.consume(Colon); err != nil {
+		return "", err
+	}
+	return label, nil
+}
+
+// hasLabel reports whether name names a loop lexically enclosing p.current -
+// the empty label (an unlabeled break/continue) always resolves, since
+// p.inLoop already guarantees there's an innermost loop to act on.
+func (p *Parser) hasLabel(name string) bool {
+	if name == "" {
+		return true
+	}
+	return slices.Contains(p.activeLabels, name)
+}
+
+// pushLabel/popLabel bracket a labeled loop's body so hasLabel sees it while
+// parsing the body and forgets it again afterward; a no-op for the
+// unlabeled form.
+func (p *Parser) pushLabel(label string) {
+	if label != "" {
+		p.activeLabels = append(p.activeLabels, label)
+	}
+}
+
+func (p *Parser) popLabel(label string) {
+	if label != "" {
+		p.activeLabels = p.activeLabels[:len(p.activeLabels)-1]
+	}
+}
+
 func (p *Parser) statement() (Statement, error) {
 	p.didEndStatement = false
+
+	label, err := p.label()
+	if err != nil {
+		return nil, err
+	}
+	if label != "" && p.current.Tag != For && p.current.Tag != While && p.current.Tag != Do {
+		return nil, p.error(p.current.Pos, "labels can only be used on for/while/do loops")
+	}
+
 	switch p.current.Tag {
 	case Print:
 		statement, err := p.printStatement()
@@ -270,12 +419,47 @@ func (p *Parser) statement() (Statement, error) {
 			return nil, err
 		}
 
+		p.pushLabel(label)
+		body, err := p.statement()
+		p.popLabel(label)
+		if err != nil {
+			return nil, err
+		}
+
+		return &StatementWhile{expr, body, label}, nil
+	case Do:
+		wasInLoop := p.inLoop
+		p.inLoop = true
+		defer func() { p.inLoop = wasInLoop }()
+
+		if err := p.consume(Do); err != nil {
+			return nil, err
+		}
+
+		p.pushLabel(label)
 		body, err := p.statement()
+		p.popLabel(label)
 		if err != nil {
 			return nil, err
 		}
 
-		return &StatementWhile{expr, body}, nil
+		if err := p.consume(While); err != nil {
+			return nil, err
+		}
+		if err := p.consume(LParen); err != nil {
+			return nil, err
+		}
+
+		expr, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := p.consume(RParen); err != nil {
+			return nil, err
+		}
+
+		return &StatementDoWhile{expr, body, label}, nil
 	case For:
 		wasInLoop := p.inLoop
 		p.inLoop = true
@@ -316,12 +500,14 @@ func (p *Parser) statement() (Statement, error) {
 					return nil, err
 				}
 
+				p.pushLabel(label)
 				body, err := p.statement()
+				p.popLabel(label)
 				if err != nil {
 					return nil, err
 				}
 
-				return &StatementForIn{ident, indexIdent, expr, body}, nil
+				return &StatementForIn{ident, indexIdent, expr, body, label}, nil
 			}
 		}
 
@@ -347,12 +533,14 @@ func (p *Parser) statement() (Statement, error) {
 			return nil, err
 		}
 
+		p.pushLabel(label)
 		body, err := p.statement()
+		p.popLabel(label)
 		if err != nil {
 			return nil, err
 		}
 
-		return &StatementFor{preExpr, expr, postExpr, body}, nil
+		return &StatementFor{preExpr, expr, postExpr, body, label}, nil
 	case LCurly:
 		block, err := p.block()
 		if err != nil {
@@ -364,14 +552,36 @@ func (p *Parser) statement() (Statement, error) {
 			return nil, p.error(p.current.Pos, "can only break inside a loop")
 		}
 		p.consume(Break)
-		stmt := StatementBreak{*p.previous}
+		breakToken := *p.previous
+		breakLabel := ""
+		if !p.atStatementEnd() && p.current.Tag == Ident {
+			breakLabel = p.lexer.GetString(p.current)
+			if !p.hasLabel(breakLabel) {
+				return nil, p.error(p.current.Pos, fmt.Sprintf("unknown label %q", breakLabel))
+			}
+			if _, err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		stmt := StatementBreak{breakToken, breakLabel}
 		return &stmt, nil
 	case Continue:
 		if !p.inLoop {
 			return nil, p.error(p.current.Pos, "can only continue inside a loop")
 		}
 		p.consume(Continue)
-		stmt := StatementContinue{*p.previous}
+		continueToken := *p.previous
+		continueLabel := ""
+		if !p.atStatementEnd() && p.current.Tag == Ident {
+			continueLabel = p.lexer.GetString(p.current)
+			if !p.hasLabel(continueLabel) {
+				return nil, p.error(p.current.Pos, fmt.Sprintf("unknown label %q", continueLabel))
+			}
+			if _, err := p.advance(); err != nil {
+				return nil, err
+			}
+		}
+		stmt := StatementContinue{continueToken, continueLabel}
 		return &stmt, nil
 	case Next:
 		p.consume(Next)
@@ -433,13 +643,49 @@ func (p *Parser) atStatementEnd() bool {
 	}
 }
 
+// statementSyncTags are the token kinds findNextStatement treats as the
+// start of a fresh statement, so recovery from one bad statement doesn't
+// also swallow every statement after it in the same block.
+var statementSyncTags = map[TokenTag]bool{
+	If:       true,
+	For:      true,
+	While:    true,
+	Do:       true,
+	Return:   true,
+	Function: true,
+	LCurly:   true,
+	RCurly:   true,
+}
+
+// findNextStatement recovers from a parse error by advancing until it
+// reaches a token that starts a new statement (see statementSyncTags) or a
+// `;` separating one statement from the next - consuming the `;` itself,
+// since it's a terminator rather than the start of what follows. It always
+// advances at least once, so a sync token sitting at p.current when it's
+// called (a statement that failed without consuming anything) doesn't
+// leave the parser stuck retrying the same position forever.
+//
+// RCurly is the one exception: it ends a block rather than starting a
+// statement, and it can already be sitting at p.current when this is
+// called - a nested block's own recovery stops right at its closing brace,
+// and the failure then propagates up to the block that contains it. Eating
+// that brace here too would desync the enclosing block() loop, which
+// expects to see its own RCurly still unconsumed. So check for it up front
+// and, if found, leave it alone for the caller.
 func (p *Parser) findNextStatement() error {
+	if p.current.Tag == RCurly {
+		return nil
+	}
 	for !p.atEnd() {
 		if _, err := p.advance(); err != nil {
 			return err
 		}
-		if p.atStatementEnd() {
-			break
+		if p.current.Tag == SemiColon {
+			_, err := p.advance()
+			return err
+		}
+		if statementSyncTags[p.current.Tag] {
+			return nil
 		}
 	}
 
@@ -453,7 +699,12 @@ func (p *Parser) expression() (Expr, error) {
 func (p *Parser) expressionWithPrec(prec Precedence) (Expr, error) {
 	prefixRule := p.rule(p.current.Tag)
 	if prefixRule.prefix == nil {
-		return nil, p.error(p.current.Pos, fmt.Sprintf("unexpected token %s", p.current.Tag))
+		for tag, rule := range p.rules {
+			if rule.prefix != nil {
+				p.noteExpected(tag)
+			}
+		}
+		return nil, p.expectedError()
 	}
 
 	lhs, err := prefixRule.prefix(p)
@@ -505,7 +756,8 @@ func identifier(p *Parser) (Expr, error) {
 		}
 		return &ExprIdentifier{*p.previous}, nil
 	}
-	return nil, p.error(p.current.Pos, "expected an identifier")
+	p.noteExpected(Dollar, Ident)
+	return nil, p.expectedError()
 }
 
 func array(p *Parser) (Expr, error) {
@@ -530,22 +782,40 @@ func object(p *Parser) (Expr, error) {
 
 	items := make([]ObjectKeyValue, 0)
 	for p.current.Tag != RCurly && !p.atEnd() {
-		err := p.consume(Str, Ident)
-		if err != nil {
-			return nil, err
-		}
-		key := p.lexer.GetString(p.previous)
-		if err = p.consume(Colon); err != nil {
-			return nil, err
-		}
-		value, err := p.expression()
-		if err != nil {
-			return nil, err
+		if p.current.Tag == Ellipsis {
+			rest, err := restPattern(p)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, ObjectKeyValue{"", rest})
+		} else {
+			err := p.consume(Str, Ident)
+			if err != nil {
+				return nil, err
+			}
+			key := p.lexer.GetString(p.previous)
+			keyToken := *p.previous
+
+			var value Expr
+			if p.current.Tag == Colon {
+				if err = p.consume(Colon); err != nil {
+					return nil, err
+				}
+				value, err = p.expression()
+				if err != nil {
+					return nil, err
+				}
+			} else {
+				// shorthand: `{ a }` means `{ a: a }`, and also binds `a`
+				// when used as a match pattern
+				value = &ExprIdentifier{keyToken}
+			}
+
+			items = append(items, ObjectKeyValue{key, value})
 		}
-		items = append(items, ObjectKeyValue{key, value})
 
 		if p.current.Tag == Comma {
-			if err = p.consume(Comma); err != nil {
+			if err := p.consume(Comma); err != nil {
 				return nil, err
 			}
 		}
@@ -558,6 +828,21 @@ func object(p *Parser) (Expr, error) {
 	return &ExprObject{token, items}, nil
 }
 
+// restPattern parses the "...name" tail of an array or object match pattern.
+func restPattern(p *Parser) (Expr, error) {
+	if err := p.consume(Ellipsis); err != nil {
+		return nil, err
+	}
+	token := *p.previous
+
+	if err := p.consume(Ident); err != nil {
+		return nil, err
+	}
+	ident := p.lexer.GetString(p.previous)
+
+	return &ExprRestPattern{token, ident}, nil
+}
+
 func computedMember(p *Parser, left Expr) (Expr, error) {
 	opToken := p.current
 	if err := p.consume(LSquare); err != nil {
@@ -675,6 +960,17 @@ func match(p *Parser) (Expr, error) {
 			p.consume(Comma)
 		}
 
+		var guard Expr
+		if p.current.Tag == If {
+			if _, err := p.advance(); err != nil {
+				return nil, err
+			}
+			guard, err = p.expression()
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		if err := p.consume(Arrow); err != nil {
 			return nil, err
 		}
@@ -700,6 +996,7 @@ func match(p *Parser) (Expr, error) {
 
 		matchCase := MatchCase{
 			Exprs: exprs,
+			Guard: guard,
 			Body:  caseBody,
 		}
 
@@ -814,7 +1111,7 @@ func binary(p *Parser, left Expr) (Expr, error) {
 	}
 
 	switch opToken.Tag {
-	case PlusEqual, MinusEqual, MultiplyEqual, DivideEqual:
+	case PlusEqual, MinusEqual, MultiplyEqual, DivideEqual, PercentEqual:
 		return p.rewriteCompundAssingment(left, expr, opToken)
 	default:
 		return &ExprBinary{
@@ -825,6 +1122,35 @@ func binary(p *Parser, left Expr) (Expr, error) {
 	}
 }
 
+// ternary parses `cond ? then : else`. Both branches recurse at PrecTernary,
+// so a chain like `a ? b : c ? d : e` groups as `a ? b : (c ? d : e)`,
+// matching the usual AWK/JS right-associative reading.
+func ternary(p *Parser, cond Expr) (Expr, error) {
+	if err := p.consume(Question); err != nil {
+		return nil, err
+	}
+
+	thenExpr, err := p.expressionWithPrec(PrecTernary)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := p.consume(Colon); err != nil {
+		return nil, err
+	}
+
+	elseExpr, err := p.expressionWithPrec(PrecTernary)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExprTernary{
+		Cond: cond,
+		Then: thenExpr,
+		Else: elseExpr,
+	}, nil
+}
+
 func is(p *Parser, left Expr) (Expr, error) {
 	if err := p.consume(Is); err != nil {
 		return nil, err
@@ -857,6 +1183,8 @@ func (p *Parser) rewriteCompundAssingment(left Expr, right Expr, opToken Token)
 		opTag = Multiply
 	case DivideEqual:
 		opTag = Divide
+	case PercentEqual:
+		opTag = Percent
 	default:
 		panic(fmt.Errorf("attempted compound assignment with %s", opToken.Tag))
 	}
@@ -942,6 +1270,77 @@ func function(p *Parser) (Expr, error) {
 	return &fn, nil
 }
 
+// boxableOperators lists the operator tags \ is allowed to box up into a
+// two-argument function. It's narrower than "has a binary infix rule" in
+// p.rules: Pipe has one too, but it's only ever evaluated inside a match
+// expression's alternation (see evalCaseMatch) and would panic as a plain
+// ExprBinary, while the *Equal compound-assign tags get rewritten into an
+// ExprAssign by binary() rather than staying a binary expression at all.
+var boxableOperators = map[TokenTag]bool{
+	Plus:         true,
+	Minus:        true,
+	Multiply:     true,
+	Divide:       true,
+	Percent:      true,
+	LessThan:     true,
+	GreaterThan:  true,
+	EqualEqual:   true,
+	BangEqual:    true,
+	LessEqual:    true,
+	GreaterEqual: true,
+	AmpAmp:       true,
+	PipePipe:     true,
+	Tilde:        true,
+	BangTilde:    true,
+}
+
+// boxedOperator parses `\ OP`, desugaring it to the same ExprFunction shape
+// as `function(a, b) { return a OP b }` so the result can be passed around
+// as an ordinary function value, e.g. reduce(nums, \+, 0) or sort(xs, \<).
+// The two parameter identifiers need real, lexeme-resolvable tokens (see
+// ExprIdentifier.token), so it registers a tiny synthetic file through the
+// shared FileSet - the same mechanism ProgramLoader uses to give an
+// included file its own position range - rather than inventing a
+// GetString override just for this one case.
+func boxedOperator(p *Parser) (Expr, error) {
+	if _, err := p.advance(); err != nil {
+		return nil, err
+	}
+	backslash := *p.previous
+
+	if !boxableOperators[p.current.Tag] {
+		return nil, p.error(p.current.Pos, fmt.Sprintf("%s can't be boxed into a function", p.current.Tag))
+	}
+
+	if _, err := p.advance(); err != nil {
+		return nil, err
+	}
+	opToken := *p.previous
+
+	file := p.lexer.FileSet().AddFile("<boxed operator>", "a b")
+	aToken := Token{Tag: Ident, Pos: file.Base, Len: 1}
+	bToken := Token{Tag: Ident, Pos: file.Base + 2, Len: 1}
+
+	body := StatementBlock{
+		token: backslash,
+		Body: []Statement{
+			&StatementReturn{
+				Expr: &ExprBinary{
+					Left:    &ExprIdentifier{aToken},
+					Right:   &ExprIdentifier{bToken},
+					OpToken: opToken,
+				},
+			},
+		},
+	}
+
+	return &ExprFunction{
+		ident: backslash,
+		Args:  []string{"a", "b"},
+		Body:  &body,
+	}, nil
+}
+
 func (p *Parser) parseRule() (Rule, error) {
 	rule := Rule{}
 	switch p.current.Tag {
@@ -1038,6 +1437,49 @@ func (p *Parser) parseFunction() (ExprFunction, error) {
 	}, nil
 }
 
+// parseImport parses `import "path"` or `import name "path"`, binding the
+// module's exports to the default name (derived from Path) or the explicit
+// alias respectively.
+func (p *Parser) parseImport() (ImportDecl, error) {
+	if err := p.consume(Import); err != nil {
+		return ImportDecl{}, err
+	}
+	token := *p.previous
+
+	alias := ""
+	if p.current.Tag == Ident {
+		if err := p.consume(Ident); err != nil {
+			return ImportDecl{}, err
+		}
+		alias = p.lexer.GetString(p.previous)
+	}
+
+	if err := p.consume(Str); err != nil {
+		return ImportDecl{}, err
+	}
+	path := p.lexer.GetString(p.previous)
+
+	return ImportDecl{token: token, Alias: alias, Path: path}, nil
+}
+
+// parseInclude parses `include "path"`. Unlike import, the parser doesn't
+// resolve it - it just records the directive for ProgramLoader, which
+// reads the included file, parses it against the same FileSet, and merges
+// its Rules/Functions into this Program.
+func (p *Parser) parseInclude() (IncludeDecl, error) {
+	if err := p.consume(Include); err != nil {
+		return IncludeDecl{}, err
+	}
+	token := *p.previous
+
+	if err := p.consume(Str); err != nil {
+		return IncludeDecl{}, err
+	}
+	path := p.lexer.GetString(p.previous)
+
+	return IncludeDecl{token: token, Path: path}, nil
+}
+
 func (p *Parser) ParseExpression() (Expr, error) {
 	if _, err := p.advance(); err != nil {
 		return nil, err
@@ -1056,17 +1498,41 @@ func (p *Parser) Parse() (Program, error) {
 	prog := Program{}
 	rules := make([]Rule, 0)
 	functions := make([]ExprFunction, 0)
-	errs := make([]error, 0)
+	imports := make([]ImportDecl, 0)
+	includes := make([]IncludeDecl, 0)
+	var errList ErrorList
 	if _, err := p.advance(); err != nil {
 		return prog, err
 	}
 	for !p.atEnd() {
+		if p.current.Tag == Import {
+			imp, err := p.parseImport()
+			if err != nil {
+				errList.Add(err)
+				if err2 := p.findNextStatement(); err2 != nil {
+					return prog, err2
+				}
+			}
+			imports = append(imports, imp)
+			continue
+		}
+		if p.current.Tag == Include {
+			inc, err := p.parseInclude()
+			if err != nil {
+				errList.Add(err)
+				if err2 := p.findNextStatement(); err2 != nil {
+					return prog, err2
+				}
+			}
+			includes = append(includes, inc)
+			continue
+		}
 		if p.current.Tag == Function {
 			fn, err := p.parseFunction()
 			if err != nil {
-				errs = append(errs, err)
+				errList.Add(err)
 				if err2 := p.findNextStatement(); err2 != nil {
-					return prog, err
+					return prog, err2
 				}
 			}
 			functions = append(functions, fn)
@@ -1074,19 +1540,21 @@ func (p *Parser) Parse() (Program, error) {
 		}
 		rule, err := p.parseRule()
 		if err != nil {
-			errs = append(errs, err)
+			errList.Add(err)
 			if err2 := p.findNextStatement(); err2 != nil {
-				return prog, err
+				return prog, err2
 			}
 		}
 		rules = append(rules, rule)
 	}
 
-	if len(errs) > 0 {
-		return prog, ErrorGroup{errs}
+	if err := errList.Err(); err != nil {
+		return prog, err
 	}
 
 	prog.Rules = rules
 	prog.Functions = functions
+	prog.Imports = imports
+	prog.Includes = includes
 	return prog, nil
 }
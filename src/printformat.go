@@ -0,0 +1,174 @@
+package lang
+
+import (
+	"fmt"
+	"io"
+)
+
+// PrintFormat selects how `print` statements render their output: "" (or
+// "text", the default) writes each print call as soon as it runs, while
+// "table" buffers every row until the program finishes so columns can be
+// aligned. Set via the -print-format CLI flag.
+var PrintFormat string
+
+// PrintCell is one value rendered by a print statement, along with whether
+// it came from a number, which the table formatter uses to decide whether a
+// column is right- or left-aligned.
+type PrintCell struct {
+	Text     string
+	IsNumber bool
+}
+
+// printFormatter receives every print statement's rendered columns and
+// decides how (and when) to write them to the program's output.
+type printFormatter interface {
+	row(header []string, cols []PrintCell)
+	finish() error
+}
+
+// newPrintFormatter builds the printFormatter named by PrintFormat, writing
+// to w.
+func newPrintFormatter(w io.Writer) printFormatter {
+	switch PrintFormat {
+	case "table":
+		return &tablePrintFormatter{w: w}
+	default:
+		return &textPrintFormatter{w: w}
+	}
+}
+
+// textPrintFormatter is today's behavior: write each row immediately as
+// space-joined columns.
+type textPrintFormatter struct {
+	w io.Writer
+}
+
+func (f *textPrintFormatter) row(header []string, cols []PrintCell) {
+	writeTextRow(f.w, cols)
+}
+
+func (f *textPrintFormatter) finish() error {
+	return nil
+}
+
+func writeTextRow(w io.Writer, cols []PrintCell) {
+	for i, col := range cols {
+		if i > 0 {
+			fmt.Fprint(w, " ")
+		}
+		fmt.Fprint(w, col.Text)
+	}
+	fmt.Fprint(w, "\n")
+}
+
+// tableRowCap bounds how many rows the table formatter will buffer before
+// giving up on alignment; beyond this it just falls back to text rows for
+// the rest of the run rather than holding an unbounded amount of output in
+// memory.
+const tableRowCap = 10000
+
+// tablePrintFormatter buffers rows until finish(), then prints them as an
+// aligned table: numeric columns right-aligned, everything else
+// left-aligned, with an optional header row derived from the first
+// whole-record print (see StatementPrint in evaluator.go).
+type tablePrintFormatter struct {
+	w        io.Writer
+	header   []string
+	rows     [][]PrintCell
+	overflow bool
+}
+
+func (f *tablePrintFormatter) row(header []string, cols []PrintCell) {
+	if f.header == nil && header != nil {
+		f.header = header
+	}
+
+	if f.overflow {
+		writeTextRow(f.w, cols)
+		return
+	}
+
+	if len(f.rows) >= tableRowCap {
+		f.overflow = true
+		f.flush()
+		writeTextRow(f.w, cols)
+		return
+	}
+
+	f.rows = append(f.rows, cols)
+}
+
+func (f *tablePrintFormatter) finish() error {
+	if !f.overflow {
+		f.flush()
+	}
+	return nil
+}
+
+func (f *tablePrintFormatter) flush() {
+	if len(f.rows) == 0 {
+		return
+	}
+
+	numCols := len(f.header)
+	for _, row := range f.rows {
+		if len(row) > numCols {
+			numCols = len(row)
+		}
+	}
+
+	widths := make([]int, numCols)
+	numeric := make([]bool, numCols)
+	for i := range numeric {
+		numeric[i] = true
+	}
+
+	for i, name := range f.header {
+		widths[i] = max(widths[i], len(name))
+	}
+	for _, row := range f.rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell.Text))
+			if !cell.IsNumber {
+				numeric[i] = false
+			}
+		}
+	}
+
+	if f.header != nil {
+		f.writeRow(rowOf(f.header), widths, numeric)
+	}
+	for _, row := range f.rows {
+		f.writeRow(row, widths, numeric)
+	}
+
+	f.rows = nil
+}
+
+func rowOf(header []string) []PrintCell {
+	cols := make([]PrintCell, len(header))
+	for i, name := range header {
+		cols[i] = PrintCell{Text: name}
+	}
+	return cols
+}
+
+func (f *tablePrintFormatter) writeRow(row []PrintCell, widths []int, numeric []bool) {
+	for i := range widths {
+		if i > 0 {
+			fmt.Fprint(f.w, "  ")
+		}
+
+		var text string
+		if i < len(row) {
+			text = row[i].Text
+		}
+
+		if numeric[i] {
+			fmt.Fprintf(f.w, "%*s", widths[i], text)
+		} else {
+			fmt.Fprintf(f.w, "%-*s", widths[i], text)
+		}
+	}
+	fmt.Fprint(f.w, "\n")
+}
@@ -0,0 +1,255 @@
+package lang
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// ProgramLoader parses a jqawk program together with every file it
+// transitively `include`s, merging their Rules/Functions/Imports into one
+// Program. Every file it touches - the root and every include - is parsed
+// against the same FileSet, so diagnostics and Lexer.GetString both resolve
+// correctly no matter which file a token came from.
+//
+// A ProgramLoader reads from an fs.FS when one is given, or from the OS
+// filesystem directly when fsys is nil - that's what cli.Run uses, and
+// it's also the implicit loader behind EvalProgram/EvalProgramStream, so a
+// bare in-memory program's `include` directives resolve relative to the
+// working directory just like a shell command would. Embedding programs
+// that want includes served from somewhere else (an embed.FS, for example)
+// can call NewProgramLoader directly.
+type ProgramLoader struct {
+	fsys fs.FS
+	fset *FileSet
+}
+
+// NewProgramLoader returns a ProgramLoader backed by fsys, or by the OS
+// filesystem if fsys is nil.
+func NewProgramLoader(fsys fs.FS) *ProgramLoader {
+	return &ProgramLoader{fsys: fsys, fset: NewFileSet()}
+}
+
+func (pl *ProgramLoader) read(name string) (string, error) {
+	var data []byte
+	var err error
+	if pl.fsys != nil {
+		data, err = fs.ReadFile(pl.fsys, name)
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// canonicalPath is the key load tracks in its cycle-detection set: an
+// absolute, cleaned path for the OS filesystem, or a cleaned fs.FS-style
+// path (no leading "/") for a virtual one.
+func (pl *ProgramLoader) canonicalPath(name string) (string, error) {
+	if pl.fsys != nil {
+		return path.Clean(name), nil
+	}
+	return filepath.Abs(name)
+}
+
+func (pl *ProgramLoader) dir(name string) string {
+	if pl.fsys != nil {
+		return path.Dir(name)
+	}
+	return filepath.Dir(name)
+}
+
+// resolve turns includePath, written in the including file that lives in
+// dir, into a path load can read.
+func (pl *ProgramLoader) resolve(dir, includePath string) string {
+	if pl.fsys != nil {
+		return path.Clean(path.Join(dir, includePath))
+	}
+	if filepath.IsAbs(includePath) {
+		return filepath.Clean(includePath)
+	}
+	return filepath.Clean(filepath.Join(dir, includePath))
+}
+
+// Load parses path, and every file it transitively includes, into one
+// merged Program. The returned Lexer belongs to path itself, but shares
+// this loader's FileSet with every included file's lexer - see
+// FileSet.Lexeme.
+func (pl *ProgramLoader) Load(rootPath string) (Program, *Lexer, error) {
+	return pl.load(rootPath, map[string]bool{})
+}
+
+// LoadFiles parses each of paths (each with its own includes resolved as
+// Load does) and concatenates them in command-line order into one Program,
+// the same way classic awk concatenates multiple `-f` scripts - no
+// BEGIN/END reordering between files; that only happens within a single
+// file's own include tree (see mergeIncludedRules).
+func (pl *ProgramLoader) LoadFiles(paths []string) (Program, *Lexer, error) {
+	var merged Program
+	var lex *Lexer
+	for _, p := range paths {
+		prog, l, err := pl.Load(p)
+		if err != nil {
+			return Program{}, nil, err
+		}
+		merged.Includes = append(merged.Includes, prog.Includes...)
+		merged.Imports = append(merged.Imports, prog.Imports...)
+		merged.Functions = append(merged.Functions, prog.Functions...)
+		merged.Rules = append(merged.Rules, prog.Rules...)
+		if lex == nil {
+			lex = l
+		}
+	}
+	return merged, lex, nil
+}
+
+func (pl *ProgramLoader) load(name string, seen map[string]bool) (Program, *Lexer, error) {
+	canon, err := pl.canonicalPath(name)
+	if err != nil {
+		return Program{}, nil, fmt.Errorf("could not resolve %q: %s", name, err.Error())
+	}
+	if seen[canon] {
+		return Program{}, nil, fmt.Errorf("include cycle detected at %q", name)
+	}
+	seen[canon] = true
+	defer delete(seen, canon)
+
+	src, err := pl.read(name)
+	if err != nil {
+		return Program{}, nil, fmt.Errorf("could not read %q: %s", name, err.Error())
+	}
+
+	file := pl.fset.AddFile(name, src)
+	lex := NewLexerInFile(pl.fset, file)
+	parser := NewParser(&lex)
+	prog, err := parser.Parse()
+	if err != nil {
+		return Program{}, nil, err
+	}
+
+	dir := pl.dir(name)
+	var includedRules []Rule
+	var includedFunctions []ExprFunction
+	var includedImports []ImportDecl
+	for _, inc := range prog.Includes {
+		incProg, _, err := pl.load(pl.resolve(dir, inc.Path), seen)
+		if err != nil {
+			return Program{}, nil, newSyntaxError(pl.fset, inc.token.Pos, err.Error())
+		}
+		includedRules = append(includedRules, incProg.Rules...)
+		includedFunctions = append(includedFunctions, incProg.Functions...)
+		includedImports = append(includedImports, incProg.Imports...)
+	}
+
+	prog.Rules = mergeIncludedRules(includedRules, prog.Rules)
+	prog.Functions = append(includedFunctions, prog.Functions...)
+	prog.Imports = append(includedImports, prog.Imports...)
+	prog.Includes = nil
+
+	return prog, &lex, nil
+}
+
+// mergeIncludedRules combines a file's own rules with everything pulled in
+// by its include directives: BEGIN (and pattern/BEGINFILE/ENDFILE) rules
+// from includes run before the including file's own, since includes read
+// like the setup the rest of the file depends on, but END rules from
+// includes run after the including file's own - teardown happens in the
+// reverse order setup did.
+func mergeIncludedRules(included, own []Rule) []Rule {
+	merged := make([]Rule, 0, len(included)+len(own))
+	var ownEnd []Rule
+	for _, r := range included {
+		if r.Kind != EndRule {
+			merged = append(merged, r)
+		}
+	}
+	for _, r := range own {
+		if r.Kind == EndRule {
+			ownEnd = append(ownEnd, r)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	merged = append(merged, ownEnd...)
+	for _, r := range included {
+		if r.Kind == EndRule {
+			merged = append(merged, r)
+		}
+	}
+	return merged
+}
+
+// NamedSource is one in-memory script passed to LoadSources - the in-memory
+// equivalent of a LoadFiles path, for an embedder that already has script
+// text (e.g. compiled into its binary) rather than a path to read.
+type NamedSource struct {
+	Name string
+	Src  string
+	// Dir is where Name's own `include` directives resolve relative to,
+	// the same role the dir parameter plays in LoadSource.
+	Dir string
+}
+
+// LoadSources parses each of sources (each with its own includes resolved
+// as LoadSource does) and concatenates them in order into one Program, the
+// in-memory counterpart to LoadFiles - no BEGIN/END reordering between
+// sources, only within a single source's own include tree.
+func (pl *ProgramLoader) LoadSources(sources []NamedSource) (Program, *Lexer, error) {
+	var merged Program
+	var lex *Lexer
+	for _, s := range sources {
+		prog, l, err := pl.LoadSource(s.Name, s.Src, s.Dir)
+		if err != nil {
+			return Program{}, nil, err
+		}
+		merged.Includes = append(merged.Includes, prog.Includes...)
+		merged.Imports = append(merged.Imports, prog.Imports...)
+		merged.Functions = append(merged.Functions, prog.Functions...)
+		merged.Rules = append(merged.Rules, prog.Rules...)
+		if lex == nil {
+			lex = l
+		}
+	}
+	return merged, lex, nil
+}
+
+// LoadSource parses src as if it were the contents of name - registering
+// it with this loader's FileSet under that name - resolving any top-level
+// `include` directives it contains relative to dir. EvalProgram and
+// EvalProgramStream use this so an inline program (passed as a plain
+// string, with no file of its own) can still `include` other files,
+// relative to the working directory.
+func (pl *ProgramLoader) LoadSource(name, src, dir string) (Program, *Lexer, error) {
+	file := pl.fset.AddFile(name, src)
+	lex := NewLexerInFile(pl.fset, file)
+	parser := NewParser(&lex)
+	prog, err := parser.Parse()
+	if err != nil {
+		return Program{}, nil, err
+	}
+
+	var includedRules []Rule
+	var includedFunctions []ExprFunction
+	var includedImports []ImportDecl
+	seen := map[string]bool{name: true}
+	for _, inc := range prog.Includes {
+		incProg, _, err := pl.load(pl.resolve(dir, inc.Path), seen)
+		if err != nil {
+			return Program{}, nil, newSyntaxError(pl.fset, inc.token.Pos, err.Error())
+		}
+		includedRules = append(includedRules, incProg.Rules...)
+		includedFunctions = append(includedFunctions, incProg.Functions...)
+		includedImports = append(includedImports, incProg.Imports...)
+	}
+
+	prog.Rules = mergeIncludedRules(includedRules, prog.Rules)
+	prog.Functions = append(includedFunctions, prog.Functions...)
+	prog.Imports = append(includedImports, prog.Imports...)
+	prog.Includes = nil
+
+	return prog, &lex, nil
+}
@@ -2,16 +2,104 @@ package lang
 
 import (
 	"cmp"
+	"fmt"
 	"math"
+	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
+// replaceFirst replaces only the left-most match of re in s with repl -
+// "replace" is the one-shot sibling of "replaceAll", which just wraps
+// regexp.Regexp.ReplaceAllString directly since that already replaces every
+// match.
+func replaceFirst(re *regexp.Regexp, s, repl string) string {
+	loc := re.FindStringIndex(s)
+	if loc == nil {
+		return s
+	}
+	expanded := re.ReplaceAllString(s[loc[0]:loc[1]], repl)
+	return s[:loc[0]] + expanded + s[loc[1]:]
+}
+
+// padStart and padEnd grow s to targetLen by repeating pad on the left or
+// right respectively, truncating from the side the padding was added on if
+// s plus a whole number of pads overshoots targetLen. Like the rest of this
+// file's string indexing, both operate byte-wise rather than rune-wise.
+func padStart(s string, targetLen int, pad string) string {
+	if pad == "" || len(s) >= targetLen {
+		return s
+	}
+	padded := s
+	for len(padded) < targetLen {
+		padded = pad + padded
+	}
+	return padded[len(padded)-targetLen:]
+}
+
+func padEnd(s string, targetLen int, pad string) string {
+	if pad == "" || len(s) >= targetLen {
+		return s
+	}
+	padded := s
+	for len(padded) < targetLen {
+		padded = padded + pad
+	}
+	return padded[:targetLen]
+}
+
+// clampStringIndex resolves a possibly-negative, possibly-out-of-range
+// string.slice index the same way Value.GetMember resolves array/string
+// indices: negative counts back from length, and the result is clamped into
+// [0, length] instead of erroring, since slice is expected to tolerate an
+// end past the string's end.
+func clampStringIndex(i, length int) int {
+	if i < 0 {
+		i += length
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > length {
+		return length
+	}
+	return i
+}
+
 var arrayPrototype *Value = nil
 var objPrototype *Value = nil
 var strPrototype *Value = nil
 var numPrototype *Value = nil
 
+// PrototypeMembers returns the sorted member names tag's prototype defines
+// (e.g. "map", "filter", "sort" for ValueArray), or nil for a tag with no
+// prototype. It exists for tooling like the REPL's tab completion rather
+// than normal member lookup, which goes through Value.GetMember instead.
+func PrototypeMembers(tag ValueTag) []string {
+	var proto *Value
+	switch tag {
+	case ValueArray:
+		proto = getArrayPrototype()
+	case ValueObj:
+		proto = getObjPrototype()
+	case ValueStr:
+		proto = getStrPrototype()
+	case ValueNum:
+		proto = getNumPrototype()
+	default:
+		return nil
+	}
+
+	names := make([]string, 0, len(*proto.Obj))
+	for name := range *proto.Obj {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
 func getArrayPrototype() *Value {
 	if arrayPrototype == nil {
 		proto := map[string]*Cell{
@@ -119,6 +207,15 @@ func getArrayPrototype() *Value {
 						return nil, nil
 					}
 
+					var cmpFn *Value
+					if len(v) > 0 {
+						fn, err := checkFnArg(v, 0)
+						if err != nil {
+							return nil, err
+						}
+						cmpFn = fn
+					}
+
 					// is this array only numbers?
 					onlyNumbers := true
 					for _, item := range this.Array {
@@ -135,17 +232,200 @@ func getArrayPrototype() *Value {
 						copyValue(item, clone[i])
 					}
 
+					var sortErr error
 					slices.SortStableFunc(clone, func(a *Cell, b *Cell) int {
+						if sortErr != nil {
+							return 0
+						}
+						if cmpFn != nil {
+							result, err := e.CallFunction(cmpFn, []*Value{&a.Value, &b.Value}, nil)
+							if err != nil {
+								sortErr = err
+								return 0
+							}
+							return cmp.Compare(result.asFloat64(), 0)
+						}
 						if onlyNumbers {
 							return cmp.Compare(*a.Value.Num, *b.Value.Num)
 						}
 						return cmp.Compare(a.Value.String(), b.Value.String())
 					})
+					if sortErr != nil {
+						return nil, sortErr
+					}
 
 					retVal := NewValue(clone)
 					return &retVal, nil
 				},
 			}),
+			"map": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil {
+						return nil, nil
+					}
+					fn, err := checkFnArg(v, 0)
+					if err != nil {
+						return nil, err
+					}
+
+					mapped := make([]*Cell, len(this.Array))
+					for i, item := range this.Array {
+						index := NewValue(i)
+						result, err := e.CallFunction(fn, []*Value{&item.Value, &index}, nil)
+						if err != nil {
+							return nil, err
+						}
+						mapped[i] = NewCell(*result)
+					}
+
+					retVal := NewValue(mapped)
+					return &retVal, nil
+				},
+			}),
+			"filter": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil {
+						return nil, nil
+					}
+					fn, err := checkFnArg(v, 0)
+					if err != nil {
+						return nil, err
+					}
+
+					filtered := make([]*Cell, 0, len(this.Array))
+					for i, item := range this.Array {
+						index := NewValue(i)
+						result, err := e.CallFunction(fn, []*Value{&item.Value, &index}, nil)
+						if err != nil {
+							return nil, err
+						}
+						if result.isTruthy() {
+							filtered = append(filtered, NewCell(item.Value))
+						}
+					}
+
+					retVal := NewValue(filtered)
+					return &retVal, nil
+				},
+			}),
+			"reduce": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil {
+						return nil, nil
+					}
+					fn, err := checkFnArg(v, 0)
+					if err != nil {
+						return nil, err
+					}
+
+					items := this.Array
+					var acc Value
+					if len(v) > 1 {
+						acc = *v[1]
+					} else {
+						if len(items) == 0 {
+							return nil, fmt.Errorf("reduce of an empty array with no initial value")
+						}
+						acc = items[0].Value
+						items = items[1:]
+					}
+
+					for i, item := range items {
+						index := NewValue(i)
+						result, err := e.CallFunction(fn, []*Value{&acc, &item.Value, &index}, nil)
+						if err != nil {
+							return nil, err
+						}
+						acc = *result
+					}
+
+					return &acc, nil
+				},
+			}),
+			"find": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil {
+						return nil, nil
+					}
+					fn, err := checkFnArg(v, 0)
+					if err != nil {
+						return nil, err
+					}
+
+					for i, item := range this.Array {
+						index := NewValue(i)
+						result, err := e.CallFunction(fn, []*Value{&item.Value, &index}, nil)
+						if err != nil {
+							return nil, err
+						}
+						if result.isTruthy() {
+							found := item.Value
+							return &found, nil
+						}
+					}
+
+					notFound := NewValue(nil)
+					return &notFound, nil
+				},
+			}),
+			"some": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil {
+						return nil, nil
+					}
+					fn, err := checkFnArg(v, 0)
+					if err != nil {
+						return nil, err
+					}
+
+					for i, item := range this.Array {
+						index := NewValue(i)
+						result, err := e.CallFunction(fn, []*Value{&item.Value, &index}, nil)
+						if err != nil {
+							return nil, err
+						}
+						if result.isTruthy() {
+							retVal := NewValue(true)
+							return &retVal, nil
+						}
+					}
+
+					retVal := NewValue(false)
+					return &retVal, nil
+				},
+			}),
+			"every": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil {
+						return nil, nil
+					}
+					fn, err := checkFnArg(v, 0)
+					if err != nil {
+						return nil, err
+					}
+
+					for i, item := range this.Array {
+						index := NewValue(i)
+						result, err := e.CallFunction(fn, []*Value{&item.Value, &index}, nil)
+						if err != nil {
+							return nil, err
+						}
+						if !result.isTruthy() {
+							retVal := NewValue(false)
+							return &retVal, nil
+						}
+					}
+
+					retVal := NewValue(true)
+					return &retVal, nil
+				},
+			}),
 		}
 		arrayPrototype = &Value{
 			Tag: ValueObj,
@@ -181,7 +461,7 @@ func getObjPrototype() *Value {
 				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
 					newObj := NewObject()
 					for _, value := range v {
-						val, err := this.GetMember(*value)
+						val, _, err := this.GetMember(*value)
 						if err != nil {
 							return nil, err
 						}
@@ -199,6 +479,93 @@ func getObjPrototype() *Value {
 					return &newObj, nil
 				},
 			}),
+			"keys": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueObj {
+						r := NewArray()
+						return &r, nil
+					}
+
+					cells := make([]*Cell, len(this.ObjKeys))
+					for i, key := range this.ObjKeys {
+						cells[i] = NewCell(NewString(key))
+					}
+					r := NewValue(cells)
+					return &r, nil
+				},
+			}),
+			"values": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueObj {
+						r := NewArray()
+						return &r, nil
+					}
+
+					cells := make([]*Cell, len(this.ObjKeys))
+					for i, key := range this.ObjKeys {
+						cells[i] = NewCell((*this.Obj)[key].Value)
+					}
+					r := NewValue(cells)
+					return &r, nil
+				},
+			}),
+			"entries": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueObj {
+						r := NewArray()
+						return &r, nil
+					}
+
+					cells := make([]*Cell, len(this.ObjKeys))
+					for i, key := range this.ObjKeys {
+						entry := NewValue([]*Cell{
+							NewCell(NewString(key)),
+							NewCell((*this.Obj)[key].Value),
+						})
+						cells[i] = NewCell(entry)
+					}
+					r := NewValue(cells)
+					return &r, nil
+				},
+			}),
+			// merge combines this object with other, key by key, in order -
+			// keys from other overwrite this's where they collide, but keep
+			// their original position if this had them first, matching how
+			// object spread behaves in most languages with this feature.
+			"merge": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueObj {
+						r := NewObject()
+						return &r, nil
+					}
+					other, err := checkArg(v, 0, ValueObj)
+					if err != nil {
+						return nil, err
+					}
+
+					merged := map[string]*Cell{}
+					mergedKeys := make([]string, 0, len(this.ObjKeys)+len(other.ObjKeys))
+					for _, key := range this.ObjKeys {
+						if _, ok := merged[key]; !ok {
+							mergedKeys = append(mergedKeys, key)
+						}
+						merged[key] = NewCell((*this.Obj)[key].Value)
+					}
+					for _, key := range other.ObjKeys {
+						if _, ok := merged[key]; !ok {
+							mergedKeys = append(mergedKeys, key)
+						}
+						merged[key] = NewCell((*other.Obj)[key].Value)
+					}
+
+					r := Value{Tag: ValueObj, Obj: &merged, ObjKeys: mergedKeys, Proto: getObjPrototype()}
+					return &r, nil
+				},
+			}),
 		}
 		objPrototype = &Value{
 			Tag: ValueObj,
@@ -270,6 +637,274 @@ func getStrPrototype() *Value {
 					return &upper, nil
 				},
 			}),
+			"trim": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueStr {
+						r := NewValue(nil)
+						return &r, nil
+					}
+
+					r := NewValue(strings.TrimSpace(*this.Str))
+					return &r, nil
+				},
+			}),
+			"trimStart": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueStr {
+						r := NewValue(nil)
+						return &r, nil
+					}
+
+					r := NewValue(strings.TrimLeftFunc(*this.Str, unicode.IsSpace))
+					return &r, nil
+				},
+			}),
+			"trimEnd": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueStr {
+						r := NewValue(nil)
+						return &r, nil
+					}
+
+					r := NewValue(strings.TrimRightFunc(*this.Str, unicode.IsSpace))
+					return &r, nil
+				},
+			}),
+			// replace/replaceAll's pattern accepts either a regex literal or
+			// a plain string, compiled as a regex either way - the same two
+			// tags the `~`/`!~` operators accept on their right-hand side.
+			"replace": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueStr {
+						r := NewValue(nil)
+						return &r, nil
+					}
+					pattern, err := checkRegexArg(v, 0)
+					if err != nil {
+						return nil, err
+					}
+					repl, err := checkArg(v, 1, ValueStr)
+					if err != nil {
+						return nil, err
+					}
+
+					re, err := regexp.Compile(pattern)
+					if err != nil {
+						return nil, err
+					}
+
+					r := NewValue(replaceFirst(re, *this.Str, *repl.Str))
+					return &r, nil
+				},
+			}),
+			"replaceAll": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueStr {
+						r := NewValue(nil)
+						return &r, nil
+					}
+					pattern, err := checkRegexArg(v, 0)
+					if err != nil {
+						return nil, err
+					}
+					repl, err := checkArg(v, 1, ValueStr)
+					if err != nil {
+						return nil, err
+					}
+
+					re, err := regexp.Compile(pattern)
+					if err != nil {
+						return nil, err
+					}
+
+					r := NewValue(re.ReplaceAllString(*this.Str, *repl.Str))
+					return &r, nil
+				},
+			}),
+			"startsWith": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueStr {
+						r := NewValue(false)
+						return &r, nil
+					}
+					prefix, err := checkArg(v, 0, ValueStr)
+					if err != nil {
+						return nil, err
+					}
+
+					r := NewValue(strings.HasPrefix(*this.Str, *prefix.Str))
+					return &r, nil
+				},
+			}),
+			"endsWith": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueStr {
+						r := NewValue(false)
+						return &r, nil
+					}
+					suffix, err := checkArg(v, 0, ValueStr)
+					if err != nil {
+						return nil, err
+					}
+
+					r := NewValue(strings.HasSuffix(*this.Str, *suffix.Str))
+					return &r, nil
+				},
+			}),
+			"contains": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueStr {
+						r := NewValue(false)
+						return &r, nil
+					}
+					needle, err := checkArg(v, 0, ValueStr)
+					if err != nil {
+						return nil, err
+					}
+
+					r := NewValue(strings.Contains(*this.Str, *needle.Str))
+					return &r, nil
+				},
+			}),
+			"indexOf": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueStr {
+						r := NewValue(-1)
+						return &r, nil
+					}
+					needle, err := checkArg(v, 0, ValueStr)
+					if err != nil {
+						return nil, err
+					}
+
+					r := NewValue(strings.Index(*this.Str, *needle.Str))
+					return &r, nil
+				},
+			}),
+			"repeat": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueStr {
+						r := NewValue(nil)
+						return &r, nil
+					}
+					countArg, err := checkArg(v, 0, ValueNum)
+					if err != nil {
+						return nil, err
+					}
+					count := int(*countArg.Num)
+					if count < 0 {
+						return nil, fmt.Errorf("repeat count must not be negative")
+					}
+
+					r := NewValue(strings.Repeat(*this.Str, count))
+					return &r, nil
+				},
+			}),
+			"padStart": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueStr {
+						r := NewValue(nil)
+						return &r, nil
+					}
+					lengthArg, err := checkArg(v, 0, ValueNum)
+					if err != nil {
+						return nil, err
+					}
+					pad := " "
+					if len(v) > 1 {
+						padArg, err := checkArg(v, 1, ValueStr)
+						if err != nil {
+							return nil, err
+						}
+						pad = *padArg.Str
+					}
+
+					r := NewValue(padStart(*this.Str, int(*lengthArg.Num), pad))
+					return &r, nil
+				},
+			}),
+			"padEnd": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueStr {
+						r := NewValue(nil)
+						return &r, nil
+					}
+					lengthArg, err := checkArg(v, 0, ValueNum)
+					if err != nil {
+						return nil, err
+					}
+					pad := " "
+					if len(v) > 1 {
+						padArg, err := checkArg(v, 1, ValueStr)
+						if err != nil {
+							return nil, err
+						}
+						pad = *padArg.Str
+					}
+
+					r := NewValue(padEnd(*this.Str, int(*lengthArg.Num), pad))
+					return &r, nil
+				},
+			}),
+			"slice": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueStr {
+						r := NewValue(nil)
+						return &r, nil
+					}
+					startArg, err := checkArg(v, 0, ValueNum)
+					if err != nil {
+						return nil, err
+					}
+
+					str := *this.Str
+					start := clampStringIndex(int(*startArg.Num), len(str))
+					end := len(str)
+					if len(v) > 1 {
+						endArg, err := checkArg(v, 1, ValueNum)
+						if err != nil {
+							return nil, err
+						}
+						end = clampStringIndex(int(*endArg.Num), len(str))
+					}
+					if end < start {
+						end = start
+					}
+
+					r := NewValue(str[start:end])
+					return &r, nil
+				},
+			}),
+			"chars": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueStr {
+						r := NewArray()
+						return &r, nil
+					}
+
+					cells := make([]*Cell, 0, len(*this.Str))
+					for _, ch := range *this.Str {
+						cells = append(cells, NewCell(NewString(string(ch))))
+					}
+
+					r := NewValue(cells)
+					return &r, nil
+				},
+			}),
 		}
 		strPrototype = &Value{
 			Tag: ValueObj,
@@ -318,6 +953,115 @@ func getNumPrototype() *Value {
 					return &result, nil
 				},
 			}),
+			"abs": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueNum {
+						v := NewValue(nil)
+						return &v, nil
+					}
+
+					result := NewValue(math.Abs(*this.Num))
+					return &result, nil
+				},
+			}),
+			"sign": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueNum {
+						v := NewValue(nil)
+						return &v, nil
+					}
+
+					switch {
+					case *this.Num > 0:
+						result := NewValue(1)
+						return &result, nil
+					case *this.Num < 0:
+						result := NewValue(-1)
+						return &result, nil
+					default:
+						result := NewValue(0)
+						return &result, nil
+					}
+				},
+			}),
+			"isNaN": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueNum {
+						result := NewValue(false)
+						return &result, nil
+					}
+
+					result := NewValue(math.IsNaN(*this.Num))
+					return &result, nil
+				},
+			}),
+			"isFinite": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueNum {
+						result := NewValue(false)
+						return &result, nil
+					}
+
+					result := NewValue(!math.IsInf(*this.Num, 0) && !math.IsNaN(*this.Num))
+					return &result, nil
+				},
+			}),
+			"pow": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueNum {
+						v := NewValue(nil)
+						return &v, nil
+					}
+					exp, err := checkArg(v, 0, ValueNum)
+					if err != nil {
+						return nil, err
+					}
+
+					result := NewValue(math.Pow(*this.Num, *exp.Num))
+					return &result, nil
+				},
+			}),
+			"toFixed": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueNum {
+						v := NewValue(nil)
+						return &v, nil
+					}
+					digitsArg, err := checkArg(v, 0, ValueNum)
+					if err != nil {
+						return nil, err
+					}
+
+					result := NewValue(strconv.FormatFloat(*this.Num, 'f', int(*digitsArg.Num), 64))
+					return &result, nil
+				},
+			}),
+			// toString(radix) renders this as an integer in the given base -
+			// fractional digits aren't representable outside base 10, so the
+			// number is truncated towards zero first, same as Go's int64
+			// conversion would do.
+			"toString": NewCell(Value{
+				Tag: ValueNativeFn,
+				NativeFn: func(e *Evaluator, v []*Value, this *Value) (*Value, error) {
+					if this == nil || this.Tag != ValueNum {
+						v := NewValue(nil)
+						return &v, nil
+					}
+					radixArg, err := checkArg(v, 0, ValueNum)
+					if err != nil {
+						return nil, err
+					}
+
+					result := NewValue(strconv.FormatInt(int64(*this.Num), int(*radixArg.Num)))
+					return &result, nil
+				},
+			}),
 		}
 		numPrototype = &Value{
 			Tag: ValueObj,
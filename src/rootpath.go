@@ -0,0 +1,150 @@
+package lang
+
+import (
+	"io"
+	"strings"
+
+	"github.com/alligator/jqawk/src/jsonpath"
+)
+
+// RootSelector is one -r/-P argument: either a jqawk expression (the
+// default, same as -r has always supported) or a JSONPath expression
+// evaluated through lang/jsonpath. Both kinds can be mixed and repeated;
+// EvalProgram evaluates each against every decoded root value and dispatches
+// every cell it yields to PatternRule bodies as its own record.
+type RootSelector struct {
+	Src      string
+	JSONPath bool
+}
+
+// EvalRootSelector evaluates a single RootSelector against rootValue. A
+// plain jqawk expression always yields exactly one cell, matching -r's
+// existing behavior; a JSONPath selector can yield any number of cells, one
+// per matched node (e.g. "$.items[*]" yields one cell per array element).
+func EvalRootSelector(sel RootSelector, rootValue Value, stdout io.Writer) ([]*Cell, error) {
+	if !sel.JSONPath {
+		cell, err := EvalExpression(sel.Src, rootValue, stdout)
+		if err != nil {
+			return nil, err
+		}
+		return []*Cell{cell}, nil
+	}
+
+	path, err := jsonpath.Parse(sel.Src)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := path.Eval(toJSONPathValue(&rootValue), jsonPathFilterEval(stdout))
+	if err != nil {
+		return nil, err
+	}
+
+	cells := make([]*Cell, 0, len(matches))
+	for _, m := range matches {
+		cells = append(cells, NewCell(fromJSONPathValue(m)))
+	}
+	return cells, nil
+}
+
+// jsonPathFilterEval lets a `[?(...)]` predicate run as an ordinary jqawk
+// expression: `@` isn't a jqawk token, so it's textually substituted for
+// `$` (the symbol EvalExpression already binds to the candidate node)
+// before parsing - simpler than teaching the lexer a second "current value"
+// symbol for a construct that only ever appears inside a JSONPath filter.
+func jsonPathFilterEval(stdout io.Writer) jsonpath.FilterEval {
+	return func(src string, node jsonpath.Value) (bool, error) {
+		exprSrc := strings.ReplaceAll(src, "@", "$")
+		cell, err := EvalExpression(exprSrc, fromJSONPathValue(node), stdout)
+		if err != nil {
+			return false, err
+		}
+		return cell.Value.isTruthy(), nil
+	}
+}
+
+// nativeJsonpath evaluates a JSONPath expression against its first argument
+// and returns an array of every matched node - the same engine -P's root
+// selector mode runs, exposed so a program can mix jq-style pattern rules
+// with ad hoc path queries instead of only selecting at the top level.
+func nativeJsonpath(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+	if err := checkArgCount(args, 2); err != nil {
+		return nil, err
+	}
+
+	pathArg, err := checkArg(args, 1, ValueStr)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := jsonpath.Parse(*pathArg.Str)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := path.Eval(toJSONPathValue(args[0]), jsonPathFilterEval(e.stdout))
+	if err != nil {
+		return nil, err
+	}
+
+	result := NewArray()
+	for _, m := range matches {
+		result.Array = append(result.Array, NewCell(fromJSONPathValue(m)))
+	}
+	return &result, nil
+}
+
+// toJSONPathValue converts a jqawk Value into jsonpath's own Value
+// representation, the same adapter shape toJQValue uses for src/jq.
+func toJSONPathValue(v *Value) jsonpath.Value {
+	switch v.Tag {
+	case ValueStr:
+		return jsonpath.Str(*v.Str)
+	case ValueNum:
+		return jsonpath.Num(v.asFloat64())
+	case ValueBool:
+		return jsonpath.Bool(*v.Bool)
+	case ValueArray:
+		items := make([]jsonpath.Value, 0, len(v.Array))
+		for _, cell := range v.Array {
+			items = append(items, toJSONPathValue(&cell.Value))
+		}
+		return jsonpath.Array(items)
+	case ValueObj:
+		vals := make(map[string]jsonpath.Value, len(*v.Obj))
+		for k, cell := range *v.Obj {
+			vals[k] = toJSONPathValue(&cell.Value)
+		}
+		return jsonpath.Object(v.ObjKeys, vals)
+	default:
+		return jsonpath.Nil()
+	}
+}
+
+// fromJSONPathValue converts a jsonpath.Value back into a jqawk Value, the
+// inverse of toJSONPathValue.
+func fromJSONPathValue(v jsonpath.Value) Value {
+	switch v.Kind {
+	case jsonpath.KindStr:
+		return NewValue(v.Str)
+	case jsonpath.KindNum:
+		return NewValue(v.Num)
+	case jsonpath.KindBool:
+		return NewValue(v.Bool)
+	case jsonpath.KindArray:
+		arr := NewArray()
+		for _, item := range v.Array {
+			arr.Array = append(arr.Array, NewCell(fromJSONPathValue(item)))
+		}
+		return arr
+	case jsonpath.KindObject:
+		obj := NewObject()
+		for _, k := range v.Keys {
+			(*obj.Obj)[k] = NewCell(fromJSONPathValue(v.Object[k]))
+		}
+		obj.ObjKeys = append(obj.ObjKeys, v.Keys...)
+		return obj
+	default:
+		return NewValue(nil)
+	}
+}
@@ -1,8 +1,12 @@
 package lang
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"os"
 	"strconv"
 	"strings"
 	"unicode"
@@ -21,6 +25,38 @@ func checkArg(args []*Value, index int, tag ValueTag) (*Value, error) {
 	return arg, nil
 }
 
+// checkFnArg is checkArg's counterpart for an argument that must be
+// callable - ValueFn or ValueNativeFn - since a plain Tag equality check
+// can't express "one of these two".
+func checkFnArg(args []*Value, index int) (*Value, error) {
+	if len(args)-1 < index {
+		return nil, fmt.Errorf("missing argument %d", index)
+	}
+
+	arg := args[index]
+	if arg.Tag != ValueFn && arg.Tag != ValueNativeFn {
+		return nil, fmt.Errorf("expected argument %d to be a function", index)
+	}
+
+	return arg, nil
+}
+
+// checkRegexArg is checkArg's counterpart for an argument that's a regex
+// source, accepted as either ValueRegex or a plain ValueStr - the same two
+// tags `~`/`!~` accept on their right-hand side.
+func checkRegexArg(args []*Value, index int) (string, error) {
+	if len(args)-1 < index {
+		return "", fmt.Errorf("missing argument %d", index)
+	}
+
+	arg := args[index]
+	if arg.Tag != ValueStr && arg.Tag != ValueRegex {
+		return "", fmt.Errorf("expected argument %d to be a string or regex", index)
+	}
+
+	return *arg.Str, nil
+}
+
 func checkArgCount(args []*Value, expectedCount int) error {
 	if len(args) != expectedCount {
 		return fmt.Errorf("expected %d argument(s)", expectedCount)
@@ -28,19 +64,51 @@ func checkArgCount(args []*Value, expectedCount int) error {
 	return nil
 }
 
-func nativePrintf(e *Evaluator, args []*Value, this *Value) (*Value, error) {
-	if len(args) < 1 {
-		return nil, fmt.Errorf("printf requires at least one argument")
+// printfFlags holds the `[flags]` portion of a `%[flags][width][.precision]conv` directive
+type printfFlags struct {
+	leftAlign bool
+	plus      bool
+	space     bool
+	alt       bool
+	zero      bool
+}
+
+func padField(str string, width int, flags printfFlags) string {
+	if width == 0 || len(str) >= width {
+		return str
+	}
+	padChar := " "
+	if flags.zero && !flags.leftAlign {
+		padChar = "0"
+	}
+	pad := strings.Repeat(padChar, width-len(str))
+	if flags.leftAlign {
+		return str + strings.Repeat(" ", width-len(str))
+	}
+	if padChar == "0" && (len(str) > 0 && (str[0] == '+' || str[0] == '-')) {
+		// keep the sign in front of the zero padding
+		return str[:1] + pad + str[1:]
 	}
+	return pad + str
+}
 
-	fmtVal, err := checkArg(args, 0, ValueStr)
-	if err != nil {
-		return nil, err
+func signPrefix(negative bool, flags printfFlags) string {
+	if negative {
+		return "-"
+	}
+	if flags.plus {
+		return "+"
 	}
+	if flags.space {
+		return " "
+	}
+	return ""
+}
 
-	fmtStr := *fmtVal.Str
+// formatPrintf implements the shared core of printf/sprintf: a full AWK-style
+// `%[flags][width][.precision]conv` directive parser
+func formatPrintf(fmtStr string, args []*Value, argIndex int) (string, error) {
 	end := len(fmtStr)
-	argIndex := 1
 	var sb strings.Builder
 
 	for i := 0; i < end; i++ {
@@ -51,35 +119,65 @@ func nativePrintf(e *Evaluator, args []*Value, this *Value) (*Value, error) {
 		}
 
 		if i == end-1 {
-			return nil, fmt.Errorf("expected something after %%")
+			return "", fmt.Errorf("expected something after %%")
 		}
 		i++
 
-		widthSpec := 0
-		padChar := " "
-		if unicode.IsDigit(rune(fmtStr[i])) || fmtStr[i] == '-' {
-			numEnd := i + 1
+		var flags printfFlags
+		for i < end {
+			switch fmtStr[i] {
+			case '-':
+				flags.leftAlign = true
+			case '+':
+				flags.plus = true
+			case ' ':
+				flags.space = true
+			case '#':
+				flags.alt = true
+			case '0':
+				flags.zero = true
+			default:
+				goto flagsDone
+			}
+			i++
+		}
+	flagsDone:
+		if i > end-1 {
+			return "", fmt.Errorf("expected something after flags")
+		}
+
+		width := 0
+		if unicode.IsDigit(rune(fmtStr[i])) {
+			numEnd := i
 			for numEnd < end && unicode.IsDigit(rune(fmtStr[numEnd])) {
 				numEnd++
 			}
-			numStr := fmtStr[i:numEnd]
-			num, err := strconv.ParseInt(numStr, 10, 64)
-			widthSpec = int(num)
-			if err != nil {
-				return nil, fmt.Errorf("invalid width specifier")
+			num, err := strconv.Atoi(fmtStr[i:numEnd])
+			if err != nil || num > 65536 {
+				return "", fmt.Errorf("width specifier too large")
 			}
-
-			// arbitrary limit
-			if num > 65536 || num < -65536 {
-				return nil, fmt.Errorf("width specifier too large")
+			width = num
+			i = numEnd
+			if i > end-1 {
+				return "", fmt.Errorf("expected something after width specifier")
 			}
+		}
 
-			i = numEnd
-			if numStr[0] == '0' {
-				padChar = "0"
+		precision := -1
+		if fmtStr[i] == '.' {
+			i++
+			numEnd := i
+			for numEnd < end && unicode.IsDigit(rune(fmtStr[numEnd])) {
+				numEnd++
+			}
+			num, err := strconv.Atoi(fmtStr[i:numEnd])
+			if err != nil {
+				num = 0
 			}
+			precision = num
+			i = numEnd
 			if i > end-1 {
-				return nil, fmt.Errorf("expected something after width specifier")
+				return "", fmt.Errorf("expected something after precision")
 			}
 		}
 
@@ -89,62 +187,291 @@ func nativePrintf(e *Evaluator, args []*Value, this *Value) (*Value, error) {
 		case 's':
 			arg, err := checkArg(args, argIndex, ValueStr)
 			if err != nil {
-				return nil, err
+				return "", err
 			}
 			argIndex++
 			argStr := arg.String()
-
-			if widthSpec > 0 && len(argStr) < widthSpec {
-				argStr = strings.Repeat(padChar, widthSpec-len(argStr)) + argStr
-			} else if widthSpec < 0 && len(argStr) < -widthSpec {
-				argStr = argStr + strings.Repeat(padChar, -widthSpec-len(argStr))
+			if precision >= 0 && precision < len(argStr) {
+				argStr = argStr[:precision]
 			}
-
-			sb.WriteString(argStr)
-		case 'f':
+			sb.WriteString(padField(argStr, width, flags))
+		case 'd', 'i':
 			arg, err := checkArg(args, argIndex, ValueNum)
 			if err != nil {
-				return nil, err
+				return "", err
 			}
 			argIndex++
-			argStr := arg.String()
-
-			if widthSpec > 0 && len(argStr) < widthSpec {
-				argStr = strings.Repeat(padChar, widthSpec-len(argStr)) + argStr
-			} else if widthSpec < 0 && len(argStr) < -widthSpec {
-				argStr = argStr + strings.Repeat(padChar, -widthSpec-len(argStr))
+			n := int64(*arg.Num)
+			argStr := signPrefix(n < 0, flags) + strconv.FormatInt(abs64(n), 10)
+			sb.WriteString(padField(argStr, width, flags))
+		case 'x', 'X':
+			arg, err := checkArg(args, argIndex, ValueNum)
+			if err != nil {
+				return "", err
 			}
-
-			sb.WriteString(argStr)
+			argIndex++
+			n := uint64(int64(*arg.Num))
+			argStr := strconv.FormatUint(n, 16)
+			if fmtStr[i] == 'X' {
+				argStr = strings.ToUpper(argStr)
+			}
+			if flags.alt && n != 0 {
+				if fmtStr[i] == 'X' {
+					argStr = "0X" + argStr
+				} else {
+					argStr = "0x" + argStr
+				}
+			}
+			sb.WriteString(padField(argStr, width, flags))
+		case 'o':
+			arg, err := checkArg(args, argIndex, ValueNum)
+			if err != nil {
+				return "", err
+			}
+			argIndex++
+			n := uint64(int64(*arg.Num))
+			argStr := strconv.FormatUint(n, 8)
+			if flags.alt && !strings.HasPrefix(argStr, "0") {
+				argStr = "0" + argStr
+			}
+			sb.WriteString(padField(argStr, width, flags))
+		case 'c':
+			if len(args)-1 < argIndex {
+				return "", fmt.Errorf("missing argument %d", argIndex)
+			}
+			arg := args[argIndex]
+			argIndex++
+			var argStr string
+			switch arg.Tag {
+			case ValueNum:
+				argStr = string(rune(int(*arg.Num)))
+			case ValueStr:
+				if len(*arg.Str) > 0 {
+					argStr = string([]rune(*arg.Str)[0])
+				}
+			default:
+				return "", fmt.Errorf("expected argument %d to have type %s or %s", argIndex-1, ValueNum, ValueStr)
+			}
+			sb.WriteString(padField(argStr, width, flags))
+		case 'f', 'F':
+			arg, err := checkArg(args, argIndex, ValueNum)
+			if err != nil {
+				return "", err
+			}
+			argIndex++
+			// no precision given keeps the historical shortest-representation
+			// behaviour so existing %f callers don't suddenly grow trailing zeros
+			argStr := signPrefix(*arg.Num < 0, flags) + strconv.FormatFloat(math.Abs(*arg.Num), 'f', precision, 64)
+			sb.WriteString(padField(argStr, width, flags))
+		case 'e', 'E':
+			arg, err := checkArg(args, argIndex, ValueNum)
+			if err != nil {
+				return "", err
+			}
+			argIndex++
+			prec := precision
+			if prec < 0 {
+				prec = 6
+			}
+			argStr := signPrefix(*arg.Num < 0, flags) + strconv.FormatFloat(math.Abs(*arg.Num), byte(fmtStr[i]), prec, 64)
+			argStr = fixExponentWidth(argStr)
+			sb.WriteString(padField(argStr, width, flags))
+		case 'g', 'G':
+			arg, err := checkArg(args, argIndex, ValueNum)
+			if err != nil {
+				return "", err
+			}
+			argIndex++
+			prec := precision
+			if prec < 0 {
+				prec = -1
+			} else if prec == 0 {
+				prec = 1
+			}
+			argStr := signPrefix(*arg.Num < 0, flags) + strconv.FormatFloat(math.Abs(*arg.Num), byte(fmtStr[i]), prec, 64)
+			argStr = fixExponentWidth(argStr)
+			sb.WriteString(padField(argStr, width, flags))
 		case 'v':
 			if len(args)-1 < argIndex {
-				return nil, fmt.Errorf("missing argument %d", argIndex)
+				return "", fmt.Errorf("missing argument %d", argIndex)
 			}
 			sb.WriteString(args[argIndex].PrettyString(false))
 			argIndex++
 		default:
-			return nil, fmt.Errorf("unknown format code %c", fmtStr[i])
+			return "", fmt.Errorf("unknown format code %c", fmtStr[i])
 		}
 	}
 
-	e.print(sb.String())
+	return sb.String(), nil
+}
+
+func abs64(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Go's strconv always renders a 2-digit exponent; awk/gawk expect the same,
+// so this is mostly a no-op, but it guards against 1-digit exponents on
+// platforms where that isn't true
+func fixExponentWidth(s string) string {
+	idx := strings.IndexAny(s, "eE")
+	if idx == -1 || idx+2 >= len(s) {
+		return s
+	}
+	digits := s[idx+2:]
+	if len(digits) == 1 {
+		return s[:idx+2] + "0" + digits
+	}
+	return s
+}
+
+func nativePrintf(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("printf requires at least one argument")
+	}
+
+	fmtVal, err := checkArg(args, 0, ValueStr)
+	if err != nil {
+		return nil, err
+	}
+
+	str, err := formatPrintf(*fmtVal.Str, args, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	e.print(str)
 	return nil, nil
 }
 
-func nativeJson(e *Evaluator, args []*Value, this *Value) (*Value, error) {
-	if err := checkArgCount(args, 1); err != nil {
+func nativeSprintf(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("sprintf requires at least one argument")
+	}
+
+	fmtVal, err := checkArg(args, 0, ValueStr)
+	if err != nil {
 		return nil, err
 	}
 
-	bytes, err := json.MarshalIndent(args[0], "", "  ")
+	str, err := formatPrintf(*fmtVal.Str, args, 1)
 	if err != nil {
-		if marshalerErr, ok := err.(*json.MarshalerError); ok {
-			return nil, fmt.Errorf("error creating JSON: %s", marshalerErr.Unwrap().Error())
+		return nil, err
+	}
+
+	v := NewValue(str)
+	return &v, nil
+}
+
+// jsonOutputOptions is the resolved form of json()'s optional second
+// argument, which callers may pass as a preset string ("compact", "pretty",
+// "ndjson") or as an options object ({indent, sortKeys, escapeHTML, compact}).
+type jsonOutputOptions struct {
+	indent     string
+	sortKeys   bool
+	escapeHTML bool
+	compact    bool
+	ndjson     bool
+}
+
+func defaultJsonOutputOptions() jsonOutputOptions {
+	return jsonOutputOptions{indent: "  ", escapeHTML: true}
+}
+
+func parseJsonOutputOptions(arg *Value) (jsonOutputOptions, error) {
+	opts := defaultJsonOutputOptions()
+	if arg == nil {
+		return opts, nil
+	}
+
+	switch arg.Tag {
+	case ValueStr:
+		switch *arg.Str {
+		case "compact":
+			opts.compact = true
+		case "pretty":
+			// defaults already produce this
+		case "ndjson":
+			opts.ndjson = true
+		default:
+			return opts, fmt.Errorf("unknown json preset %q, expected compact, pretty or ndjson", *arg.Str)
+		}
+		return opts, nil
+	case ValueObj:
+		if cell, ok := (*arg.Obj)["indent"]; ok {
+			if cell.Value.Tag != ValueStr {
+				return opts, fmt.Errorf("expected indent to be a string")
+			}
+			opts.indent = *cell.Value.Str
+		}
+		if cell, ok := (*arg.Obj)["sortKeys"]; ok {
+			opts.sortKeys = cell.Value.isTruthy()
 		}
-		return nil, fmt.Errorf("error creating JSON: %s", err.Error())
+		if cell, ok := (*arg.Obj)["escapeHTML"]; ok {
+			opts.escapeHTML = cell.Value.isTruthy()
+		}
+		if cell, ok := (*arg.Obj)["compact"]; ok {
+			opts.compact = cell.Value.isTruthy()
+		}
+		return opts, nil
+	default:
+		return opts, fmt.Errorf("expected a preset string or an options object, got %s", arg.Tag)
+	}
+}
+
+func jsonMarshalError(err error) error {
+	if marshalerErr, ok := err.(*json.MarshalerError); ok {
+		return fmt.Errorf("error creating JSON: %s", marshalerErr.Unwrap().Error())
+	}
+	return fmt.Errorf("error creating JSON: %s", err.Error())
+}
+
+// toEncodeOptions converts a resolved jsonOutputOptions into the
+// EncodeOptions Value.Encode expects - compact drops indentation entirely
+// rather than being a separate post-processing pass the way encodeJson used
+// to run json.Compact over an already-indented document.
+func (opts jsonOutputOptions) toEncodeOptions() EncodeOptions {
+	encOpts := EncodeOptions{SortKeys: opts.sortKeys, EscapeHTML: opts.escapeHTML, NDJSON: opts.ndjson}
+	if !opts.compact {
+		encOpts.Indent = opts.indent
+	}
+	return encOpts
+}
+
+// encodeJson renders val as a JSON string per opts, through Value.Encode -
+// this used to marshal compactly and then reformat with json.Indent/
+// json.Compact as a second pass; Encode produces the final shape directly.
+func encodeJson(val *Value, opts jsonOutputOptions) (string, error) {
+	var buf bytes.Buffer
+	if err := val.Encode(&buf, opts.toEncodeOptions()); err != nil {
+		return "", jsonMarshalError(err)
+	}
+	return buf.String(), nil
+}
+
+func nativeJson(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, fmt.Errorf("expected 1 or 2 argument(s)")
+	}
+
+	var optsArg *Value
+	if len(args) == 2 {
+		optsArg = args[1]
+	}
+
+	opts, err := parseJsonOutputOptions(optsArg)
+	if err != nil {
+		return nil, err
 	}
 
-	v := NewValue(string(bytes))
+	str, err := encodeJson(args[0], opts)
+	if err != nil {
+		return nil, err
+	}
+
+	v := NewValue(str)
 	return &v, nil
 }
 
@@ -171,11 +498,76 @@ func nativeNum(e *Evaluator, args []*Value, this *Value) (*Value, error) {
 	}
 }
 
+// nativeStream reads the file named by its first argument and pulls out the
+// array named by the JSONPath-ish selector in its second argument using a
+// StreamDecoder, so the surrounding document is never fully materialized
+func nativeStream(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+	if err := checkArgCount(args, 2); err != nil {
+		return nil, err
+	}
+
+	pathArg, err := checkArg(args, 0, ValueStr)
+	if err != nil {
+		return nil, err
+	}
+
+	selectorArg, err := checkArg(args, 1, ValueStr)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(*pathArg.Str)
+	if err != nil {
+		return nil, fmt.Errorf("error opening %s: %s", *pathArg.Str, err.Error())
+	}
+	defer f.Close()
+
+	sd, err := NewStreamDecoder(f, *selectorArg.Str)
+	if err != nil {
+		return nil, err
+	}
+
+	result := NewArray()
+	for {
+		v, err := sd.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Array = append(result.Array, NewCell(v))
+	}
+
+	return &result, nil
+}
+
+// nativeEmit writes value to stdout as a single compact JSON line - the same
+// shape ndjson input is read in - so a rule (most often an END block
+// summarizing or transforming a streamed input) can produce its own output
+// records without going through print/printf's column-oriented formatting.
+func nativeEmit(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+	if err := checkArgCount(args, 1); err != nil {
+		return nil, err
+	}
+
+	if err := args[0].Encode(e.stdout, EncodeOptions{EscapeHTML: true}); err != nil {
+		return nil, jsonMarshalError(err)
+	}
+	fmt.Fprintln(e.stdout)
+
+	return nil, nil
+}
+
 func addRuntimeFunctions(e *Evaluator) {
 	e.stackTop.locals["printf"] = NewCell(Value{
 		Tag:      ValueNativeFn,
 		NativeFn: nativePrintf,
 	})
+	e.stackTop.locals["sprintf"] = NewCell(Value{
+		Tag:      ValueNativeFn,
+		NativeFn: nativeSprintf,
+	})
 	e.stackTop.locals["json"] = NewCell(Value{
 		Tag:      ValueNativeFn,
 		NativeFn: nativeJson,
@@ -184,4 +576,73 @@ func addRuntimeFunctions(e *Evaluator) {
 		Tag:      ValueNativeFn,
 		NativeFn: nativeNum,
 	})
+	e.stackTop.locals["stream"] = NewCell(Value{
+		Tag:      ValueNativeFn,
+		NativeFn: nativeStream,
+	})
+	e.stackTop.locals["jq"] = NewCell(Value{
+		Tag:      ValueNativeFn,
+		NativeFn: nativeJq,
+	})
+	e.stackTop.locals["yaml"] = NewCell(Value{
+		Tag:      ValueNativeFn,
+		NativeFn: nativeYaml,
+	})
+	e.stackTop.locals["toml"] = NewCell(Value{
+		Tag:      ValueNativeFn,
+		NativeFn: nativeToml,
+	})
+	e.stackTop.locals["csv"] = NewCell(Value{
+		Tag:      ValueNativeFn,
+		NativeFn: nativeCsv,
+	})
+	e.stackTop.locals["emit"] = NewCell(Value{
+		Tag:      ValueNativeFn,
+		NativeFn: nativeEmit,
+	})
+	e.stackTop.locals["jsonpath"] = NewCell(Value{
+		Tag:      ValueNativeFn,
+		NativeFn: nativeJsonpath,
+	})
+}
+
+// nativeYaml renders its argument as YAML text via encodeYAML (block style,
+// with empty arrays/objects as `[]` / `{}`) - a text-format sibling to json(x).
+func nativeYaml(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+	if err := checkArgCount(args, 1); err != nil {
+		return nil, err
+	}
+	str, err := encodeYAML(args[0])
+	if err != nil {
+		return nil, err
+	}
+	v := NewValue(str)
+	return &v, nil
+}
+
+// nativeToml renders its argument (which must be an object) as TOML text.
+func nativeToml(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+	if err := checkArgCount(args, 1); err != nil {
+		return nil, err
+	}
+	str, err := encodeTOML(args[0])
+	if err != nil {
+		return nil, err
+	}
+	v := NewValue(str)
+	return &v, nil
+}
+
+// nativeCsv renders its argument - an array of row arrays or row objects -
+// as CSV text.
+func nativeCsv(e *Evaluator, args []*Value, this *Value) (*Value, error) {
+	if err := checkArgCount(args, 1); err != nil {
+		return nil, err
+	}
+	str, err := encodeCSV(args[0])
+	if err != nil {
+		return nil, err
+	}
+	v := NewValue(str)
+	return &v, nil
 }
@@ -0,0 +1,124 @@
+package lang
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StreamDecoder pulls one selected JSON subtree at a time out of a larger
+// document using encoding/json's token-based Decoder, so documents far
+// bigger than available memory can be processed one record at a time
+// instead of being fully materialized up front.
+//
+// The selector is a small JSONPath-like expression naming the array to
+// iterate, e.g. "$[*]" for a top-level array or "$.items[*]" for an array
+// nested under an object key. Only a single trailing "[*]" is supported.
+type StreamDecoder struct {
+	dec     *json.Decoder
+	path    []string
+	started bool
+}
+
+// NewStreamDecoder builds a StreamDecoder that walks r looking for the array
+// named by selector, yielding each of its elements in turn.
+func NewStreamDecoder(r io.Reader, selector string) (*StreamDecoder, error) {
+	path, err := parseStreamSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamDecoder{
+		dec:  json.NewDecoder(r),
+		path: path,
+	}, nil
+}
+
+func parseStreamSelector(selector string) ([]string, error) {
+	if !strings.HasPrefix(selector, "$") {
+		return nil, fmt.Errorf("stream selector must start with $")
+	}
+	selector = strings.TrimPrefix(selector, "$")
+
+	if !strings.HasSuffix(selector, "[*]") {
+		return nil, fmt.Errorf("stream selector must end with [*]")
+	}
+	selector = strings.TrimSuffix(selector, "[*]")
+	selector = strings.TrimPrefix(selector, ".")
+
+	if selector == "" {
+		return []string{}, nil
+	}
+	return strings.Split(selector, "."), nil
+}
+
+// Next decodes and returns the next element of the selected array. It
+// returns io.EOF once the array is exhausted.
+func (sd *StreamDecoder) Next() (Value, error) {
+	if !sd.started {
+		if err := sd.seek(); err != nil {
+			return Value{}, err
+		}
+		sd.started = true
+	}
+
+	if !sd.dec.More() {
+		if _, err := sd.dec.Token(); err != nil { // consume the closing ']'
+			return Value{}, err
+		}
+		return Value{}, io.EOF
+	}
+
+	var raw interface{}
+	if err := sd.dec.Decode(&raw); err != nil {
+		return Value{}, err
+	}
+	return NewValue(raw), nil
+}
+
+// seek advances the decoder past the object keys named by the selector and
+// up to the opening '[' of the selected array
+func (sd *StreamDecoder) seek() error {
+	for _, key := range sd.path {
+		tok, err := sd.dec.Token()
+		if err != nil {
+			return err
+		}
+		if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+			return fmt.Errorf("expected an object while seeking %q", key)
+		}
+
+		found := false
+		for sd.dec.More() {
+			ktok, err := sd.dec.Token()
+			if err != nil {
+				return err
+			}
+			k, ok := ktok.(string)
+			if !ok {
+				return fmt.Errorf("expected an object key")
+			}
+			if k == key {
+				found = true
+				break
+			}
+
+			var discard json.RawMessage
+			if err := sd.dec.Decode(&discard); err != nil {
+				return err
+			}
+		}
+		if !found {
+			return fmt.Errorf("stream selector key %q not found", key)
+		}
+	}
+
+	tok, err := sd.dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return fmt.Errorf("stream selector does not point at an array")
+	}
+	return nil
+}
@@ -25,51 +25,70 @@ func _() {
 	_ = x[While-14]
 	_ = x[In-15]
 	_ = x[Match-16]
-	_ = x[True-17]
-	_ = x[False-18]
-	_ = x[LCurly-19]
-	_ = x[RCurly-20]
-	_ = x[LSquare-21]
-	_ = x[RSquare-22]
-	_ = x[LParen-23]
-	_ = x[RParen-24]
-	_ = x[LessThan-25]
-	_ = x[GreaterThan-26]
-	_ = x[Dollar-27]
-	_ = x[Comma-28]
-	_ = x[Dot-29]
-	_ = x[Equal-30]
-	_ = x[EqualEqual-31]
-	_ = x[BangEqual-32]
-	_ = x[LessEqual-33]
-	_ = x[GreaterEqual-34]
-	_ = x[Colon-35]
-	_ = x[SemiColon-36]
-	_ = x[Plus-37]
-	_ = x[Minus-38]
-	_ = x[Multiply-39]
-	_ = x[Divide-40]
-	_ = x[PlusEqual-41]
-	_ = x[MinusEqual-42]
-	_ = x[MultiplyEqual-43]
-	_ = x[DivideEqual-44]
-	_ = x[Tilde-45]
-	_ = x[BangTilde-46]
-	_ = x[AmpAmp-47]
-	_ = x[PipePipe-48]
-	_ = x[Arrow-49]
-	_ = x[Bang-50]
-	_ = x[PlusPlus-51]
-	_ = x[MinusMinus-52]
+	_ = x[Break-17]
+	_ = x[Continue-18]
+	_ = x[Next-19]
+	_ = x[Newline-20]
+	_ = x[True-21]
+	_ = x[False-22]
+	_ = x[LCurly-23]
+	_ = x[RCurly-24]
+	_ = x[LSquare-25]
+	_ = x[RSquare-26]
+	_ = x[LParen-27]
+	_ = x[RParen-28]
+	_ = x[LessThan-29]
+	_ = x[GreaterThan-30]
+	_ = x[Dollar-31]
+	_ = x[Comma-32]
+	_ = x[Dot-33]
+	_ = x[Equal-34]
+	_ = x[EqualEqual-35]
+	_ = x[BangEqual-36]
+	_ = x[LessEqual-37]
+	_ = x[GreaterEqual-38]
+	_ = x[Colon-39]
+	_ = x[SemiColon-40]
+	_ = x[Plus-41]
+	_ = x[Minus-42]
+	_ = x[Multiply-43]
+	_ = x[Divide-44]
+	_ = x[PlusEqual-45]
+	_ = x[MinusEqual-46]
+	_ = x[MultiplyEqual-47]
+	_ = x[DivideEqual-48]
+	_ = x[Tilde-49]
+	_ = x[BangTilde-50]
+	_ = x[AmpAmp-51]
+	_ = x[PipePipe-52]
+	_ = x[Arrow-53]
+	_ = x[Bang-54]
+	_ = x[PlusPlus-55]
+	_ = x[MinusMinus-56]
+	_ = x[Percent-57]
+	_ = x[Import-58]
+	_ = x[Null-59]
+	_ = x[Is-60]
+	_ = x[Pipe-61]
+	_ = x[Ellipsis-62]
+	_ = x[PercentEqual-63]
+	_ = x[Include-64]
+	_ = x[Do-65]
+	_ = x[Backslash-66]
+	_ = x[Question-67]
+	_ = x[Exit-68]
+	_ = x[BeginFile-69]
+	_ = x[EndFile-70]
 }
 
-const _TokenTag_name = "EOFErrorIdentStrRegexNumBeginEndPrintFunctionReturnIfElseForWhileInMatchtruefalse{}[]()<>$,.===!=<=>=:;+-*/+=-=*=/=~!~&&||=>!++--"
+const _TokenTag_name = "EOFErrorIdentStrRegexNumBeginEndPrintFunctionReturnIfElseForWhileInMatchBreakContinueNextNewlinetruefalse{}[]()<>$,.===!=<=>=:;+-*/+=-=*=/=~!~&&||=>!++--%ImportNullIs|...%=IncludeDo\\?ExitBeginFileEndFile"
 
-var _TokenTag_index = [...]uint8{0, 3, 8, 13, 16, 21, 24, 29, 32, 37, 45, 51, 53, 57, 60, 65, 67, 72, 76, 81, 82, 83, 84, 85, 86, 87, 88, 89, 90, 91, 92, 93, 95, 97, 99, 101, 102, 103, 104, 105, 106, 107, 109, 111, 113, 115, 116, 118, 120, 122, 124, 125, 127, 129}
+var _TokenTag_index = [...]uint8{0, 3, 8, 13, 16, 21, 24, 29, 32, 37, 45, 51, 53, 57, 60, 65, 67, 72, 77, 85, 89, 96, 100, 105, 106, 107, 108, 109, 110, 111, 112, 113, 114, 115, 116, 117, 119, 121, 123, 125, 126, 127, 128, 129, 130, 131, 133, 135, 137, 139, 140, 142, 144, 146, 148, 149, 151, 153, 154, 160, 164, 166, 167, 170, 172, 179, 181, 182, 183, 187, 196, 203}
 
 func (i TokenTag) String() string {
-	if i >= TokenTag(len(_TokenTag_index)-1) {
+	idx := int(i) - 0
+	if i < 0 || idx >= len(_TokenTag_index)-1 {
 		return "TokenTag(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
-	return _TokenTag_name[_TokenTag_index[i]:_TokenTag_index[i+1]]
+	return _TokenTag_name[_TokenTag_index[idx]:_TokenTag_index[idx+1]]
 }
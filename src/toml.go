@@ -0,0 +1,248 @@
+package lang
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// tomlDecoder implements a minimal TOML subset: top-level and [section]
+// (including dotted [a.b] nesting) key/value pairs, string/number/bool
+// scalars and single-line arrays of scalars. It doesn't support array-of-
+// tables ([[...]]), inline tables, or multi-line strings.
+type tomlDecoder struct {
+	src  string
+	read bool
+}
+
+func newTomlDecoder(r io.Reader) *tomlDecoder {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return &tomlDecoder{src: ""}
+	}
+	return &tomlDecoder{src: string(b)}
+}
+
+func (d *tomlDecoder) Next() (Value, error) {
+	if d.read {
+		return Value{}, io.EOF
+	}
+	d.read = true
+	return parseTOML(d.src)
+}
+
+func parseTOML(src string) (Value, error) {
+	root := NewObject()
+	current := &root
+
+	for _, raw := range strings.Split(src, "\n") {
+		line := strings.TrimSpace(stripYamlComment(raw))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			if strings.HasPrefix(line, "[[") {
+				return Value{}, fmt.Errorf("array-of-tables ([[...]]) isn't supported")
+			}
+			path := strings.Split(strings.TrimSuffix(strings.TrimPrefix(line, "["), "]"), ".")
+			current = tomlEnsurePath(&root, path)
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return Value{}, fmt.Errorf("expected \"key = value\", got %q", line)
+		}
+		key := strings.TrimSpace(unquoteYamlScalar(strings.TrimSpace(line[:eq])))
+		rest := strings.TrimSpace(line[eq+1:])
+
+		val, err := parseTomlValue(rest)
+		if err != nil {
+			return Value{}, err
+		}
+		setYamlObjField(current, key, val)
+	}
+
+	return root, nil
+}
+
+// tomlEnsurePath walks/creates the nested object chain for a dotted
+// [a.b.c] section header and returns the innermost table.
+func tomlEnsurePath(root *Value, path []string) *Value {
+	cur := root
+	for _, key := range path {
+		key = strings.TrimSpace(key)
+		existing, ok := (*cur.Obj)[key]
+		if ok && existing.Value.Tag == ValueObj {
+			cur = &existing.Value
+			continue
+		}
+		child := NewObject()
+		setYamlObjField(cur, key, child)
+		cur = &(*cur.Obj)[key].Value
+	}
+	return cur
+}
+
+func parseTomlValue(text string) (Value, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Value{}, fmt.Errorf("expected a value")
+	}
+
+	if strings.HasPrefix(text, "[") && strings.HasSuffix(text, "]") {
+		inner := strings.TrimSpace(text[1 : len(text)-1])
+		arr := NewArray()
+		if inner != "" {
+			for _, part := range splitTomlArrayItems(inner) {
+				item, err := parseTomlValue(strings.TrimSpace(part))
+				if err != nil {
+					return Value{}, err
+				}
+				arr.Array = append(arr.Array, NewCell(item))
+			}
+		}
+		return arr, nil
+	}
+
+	if len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"' {
+		return NewValue(text[1 : len(text)-1]), nil
+	}
+	if len(text) >= 2 && text[0] == '\'' && text[len(text)-1] == '\'' {
+		return NewValue(text[1 : len(text)-1]), nil
+	}
+
+	switch text {
+	case "true":
+		return NewValue(true), nil
+	case "false":
+		return NewValue(false), nil
+	}
+
+	if n, err := strconv.ParseFloat(text, 64); err == nil {
+		return NewValue(n), nil
+	}
+
+	return Value{}, fmt.Errorf("could not parse TOML value %q", text)
+}
+
+// splitTomlArrayItems splits a single-line array's inner text on commas
+// that aren't inside a quoted string.
+func splitTomlArrayItems(inner string) []string {
+	items := make([]string, 0)
+	depth := 0
+	inQuote := byte(0)
+	start := 0
+	for i := 0; i < len(inner); i++ {
+		c := inner[i]
+		switch {
+		case inQuote != 0:
+			if c == inQuote {
+				inQuote = 0
+			}
+		case c == '"' || c == '\'':
+			inQuote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == ',' && depth == 0:
+			items = append(items, inner[start:i])
+			start = i + 1
+		}
+	}
+	if strings.TrimSpace(inner[start:]) != "" {
+		items = append(items, inner[start:])
+	}
+	return items
+}
+
+// encodeTOML renders v as TOML text. v must be an object; TOML has no
+// concept of a non-table document root.
+func encodeTOML(v *Value) (string, error) {
+	if v.Tag != ValueObj {
+		return "", fmt.Errorf("TOML output requires the root value to be an object")
+	}
+
+	var sb strings.Builder
+	if err := writeTomlTable(&sb, v, nil); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func writeTomlTable(sb *strings.Builder, obj *Value, path []string) error {
+	subtables := make([]string, 0)
+
+	for _, key := range obj.ObjKeys {
+		field := (*obj.Obj)[key].Value
+		if field.Tag == ValueObj {
+			subtables = append(subtables, key)
+			continue
+		}
+		val, err := formatTomlValue(&field)
+		if err != nil {
+			return err
+		}
+		sb.WriteString(formatTomlKey(key))
+		sb.WriteString(" = ")
+		sb.WriteString(val)
+		sb.WriteString("\n")
+	}
+
+	for _, key := range subtables {
+		field := (*obj.Obj)[key].Value
+		childPath := append(append([]string{}, path...), key)
+		sb.WriteString("\n[")
+		sb.WriteString(strings.Join(childPath, "."))
+		sb.WriteString("]\n")
+		if err := writeTomlTable(sb, &field, childPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func formatTomlKey(key string) string {
+	for _, r := range key {
+		if !(r == '_' || r == '-' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')) {
+			return strconv.Quote(key)
+		}
+	}
+	return key
+}
+
+func formatTomlValue(v *Value) (string, error) {
+	switch v.Tag {
+	case ValueStr:
+		return strconv.Quote(*v.Str), nil
+	case ValueNum:
+		return v.String(), nil
+	case ValueBool:
+		if *v.Bool {
+			return "true", nil
+		}
+		return "false", nil
+	case ValueNil:
+		return `""`, nil
+	case ValueArray:
+		items := make([]string, 0, len(v.Array))
+		for _, cell := range v.Array {
+			item := cell.Value
+			if item.Tag == ValueObj || item.Tag == ValueArray {
+				return "", fmt.Errorf("TOML arrays of tables or nested arrays aren't supported")
+			}
+			s, err := formatTomlValue(&item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	default:
+		return "", fmt.Errorf("value of type %v can't be encoded as TOML", v.Tag)
+	}
+}
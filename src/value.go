@@ -1,13 +1,23 @@
 package lang
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math/big"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// BigNumMode enables arbitrary-precision decoding of JSON integers that
+// don't fit in a float64 without losing precision (e.g. 64-bit IDs,
+// nanosecond timestamps). It's opt-in via the -bignum CLI flag since it
+// changes how numbers round-trip through json().
+var BigNumMode bool
+
 // everything in jqawk is wrapped in a Cell
 // this adds a layer of indirection so assignment works with any expression
 // e.g. a[2] returns a pointer to the cell at a[2], not the value
@@ -38,20 +48,38 @@ const (
 )
 
 type Value struct {
-	Tag       ValueTag
-	Str       *string // used by ValueStr and ValueRegex
-	Num       *float64
-	Bool      *bool
+	Tag  ValueTag
+	Str  *string // used by ValueStr and ValueRegex
+	Num  *float64
+	Bool *bool
+	// BigInt holds the exact value of an integer ValueNum decoded under
+	// BigNumMode (or computed from such values), whatever its magnitude.
+	// Num is still populated (as the nearest float64 approximation) for code
+	// that hasn't been taught to look at BigInt.
+	BigInt    *big.Int
 	Array     []*Cell
 	Obj       *map[string]*Cell
 	ObjKeys   []string
-	NativeFn  func(*Evaluator, []*Value, *Value) (*Value, error)
-	Fn        *ExprFunction
+	NativeFn  NativeFn
+	Fn        FnWithContext
 	Proto     *Value
 	Binding   *Value
 	ParentObj *Value
 }
 
+// NativeFn is a Go function exposed to jqawk code as a callable value - the
+// signature every ValueNativeFn's NativeFn field and every host/prototype
+// method (hostfuncs.go's wrapHostFunc, prototypes.go) implements.
+type NativeFn func(*Evaluator, []*Value, *Value) (*Value, error)
+
+// FnWithContext pairs a function's AST with the stack frame it closed over,
+// so a call can restore that frame (see Evaluator.swapStackTop) instead of
+// running in whatever frame happened to be on top when it was called.
+type FnWithContext struct {
+	Expr    *ExprFunction
+	Context *stackFrame
+}
+
 func NewValue(srcVal interface{}) Value {
 	switch val := srcVal.(type) {
 	case []*Cell:
@@ -126,6 +154,19 @@ func NewValue(srcVal interface{}) Value {
 	}
 }
 
+// NewBigIntValue builds a ValueNum that remembers its exact integer value,
+// for every JSON integer decoded under BigNumMode, including those too large
+// to round-trip through a float64. f is the nearest float64 approximation, kept around for code
+// that only knows how to deal with Num.
+func NewBigIntValue(i *big.Int, f float64) Value {
+	return Value{
+		Tag:    ValueNum,
+		Num:    &f,
+		BigInt: i,
+		Proto:  getNumPrototype(),
+	}
+}
+
 func NewArray() Value {
 	arr := make([]*Cell, 0)
 	return Value{
@@ -159,6 +200,9 @@ func (v *Value) String() string {
 	case ValueStr:
 		return *v.Str
 	case ValueNum:
+		if v.BigInt != nil {
+			return v.BigInt.String()
+		}
 		return strconv.FormatFloat(*v.Num, 'f', -1, 64)
 	default:
 		return ""
@@ -207,6 +251,9 @@ func (v *Value) prettyStringInteral(rootValues []*Value, quote bool, checkCircul
 		}
 		return *v.Str
 	case ValueNum:
+		if v.BigInt != nil {
+			return v.BigInt.String()
+		}
 		return strconv.FormatFloat(*v.Num, 'f', -1, 64)
 	case ValueBool:
 		if *v.Bool {
@@ -248,7 +295,19 @@ func (v *Value) prettyStringInteral(rootValues []*Value, quote bool, checkCircul
 	}
 }
 
-func (v *Value) GetMember(member Value) (*Cell, error) {
+// maxArrayAutoFill bounds how many empty Cells a single out-of-range array
+// index is allowed to allocate (e.g. `a[n] = x` on an array shorter than n).
+// Array is a plain []*Cell everywhere it's used, so there's no way to grow
+// it to accommodate a huge index without materializing every slot in
+// between - this just keeps one stray index from exhausting memory.
+const maxArrayAutoFill = 1024 * 1024
+
+// GetMember looks up member on v, returning present=true when v itself (not
+// a fallback to v.Proto) owns that index/key - assignToTarget uses present
+// to tell "this path segment already exists" apart from "this is a
+// prototype method/field I shouldn't write through" when deciding whether
+// to auto-vivify an intermediate segment.
+func (v *Value) GetMember(member Value) (*Cell, bool, error) {
 	switch v.Tag {
 	case ValueArray:
 		if member.Tag != ValueNum && v.Proto != nil {
@@ -261,15 +320,19 @@ func (v *Value) GetMember(member Value) (*Cell, error) {
 			index = len(arr) + index
 			if index < 0 {
 				// walked backwards off the front of the array
-				return nil, fmt.Errorf("index out of range")
+				return nil, false, fmt.Errorf("index out of range")
 			}
 		}
 
 		if index >= len(arr) {
-			// TODO sparse arrays
-			// don't fill up to enormous numbers, just bail
-			if index > 1024*1024 {
-				return nil, fmt.Errorf("index too large to auto-fill array")
+			// Array is always a fully materialized []*Cell (every other
+			// consumer - Encode, PrettyString, the prototype methods, the
+			// evaluator's for-loops - ranges over it directly as a plain
+			// slice), so there's no cheap way to auto-fill a huge gap
+			// without actually allocating a Cell per slot. Bail rather than
+			// let one out-of-range index OOM the process.
+			if index-len(arr) > maxArrayAutoFill {
+				return nil, false, fmt.Errorf("index too large to auto-fill array (can't grow by more than %d elements at once)", maxArrayAutoFill)
 			}
 
 			// fill the array with empty cells up to the index
@@ -285,22 +348,22 @@ func (v *Value) GetMember(member Value) (*Cell, error) {
 			fIndex := float64(index)
 			lastCell.Value.Num = &fIndex
 
-			return lastCell, nil
+			return lastCell, false, nil
 		}
-		return arr[index], nil
+		return arr[index], true, nil
 	case ValueObj:
 		if member.Tag != ValueNum && member.Tag != ValueStr {
-			return nil, fmt.Errorf("objects can only by indexed with numbers or strings, got %s", member.Tag)
+			return nil, false, fmt.Errorf("objects can only by indexed with numbers or strings, got %s", member.Tag)
 		}
 		key := member.String()
 		value, present := (*v.Obj)[key]
 		if present {
-			return value, nil
+			return value, true, nil
 		}
 		if v.Proto != nil {
 			return v.Proto.GetMember(member)
 		}
-		return nil, nil
+		return nil, false, nil
 	case ValueStr:
 		if member.Tag != ValueNum {
 			return v.Proto.GetMember(member)
@@ -312,19 +375,19 @@ func (v *Value) GetMember(member Value) (*Cell, error) {
 			index = len(str) + index
 			if index < 0 {
 				// walked backwards off the front of the array
-				return nil, fmt.Errorf("index out of range")
+				return nil, false, fmt.Errorf("index out of range")
 			}
 		}
 
 		if index < 0 || index >= len(*v.Str) {
-			return NewCell(NewValue(nil)), nil
+			return NewCell(NewValue(nil)), false, nil
 		}
-		return NewCell(NewString(string((*v.Str)[index]))), nil
+		return NewCell(NewString(string((*v.Str)[index]))), true, nil
 	default:
 		if v.Proto != nil {
 			return v.Proto.GetMember(member)
 		}
-		return nil, nil
+		return nil, false, nil
 	}
 }
 
@@ -335,7 +398,7 @@ func (v *Value) SetMember(member Value, cell *Cell) (*Cell, error) {
 			return nil, fmt.Errorf("array indices must be numbers")
 		}
 
-		item, err := v.GetMember(member)
+		item, _, err := v.GetMember(member)
 		if err != nil {
 			return nil, err
 		}
@@ -343,8 +406,11 @@ func (v *Value) SetMember(member Value, cell *Cell) (*Cell, error) {
 		return item, nil
 	case ValueObj:
 		key := member.String()
+		_, exists := (*v.Obj)[key]
 		(*v.Obj)[key] = cell
-		v.ObjKeys = append(v.ObjKeys, key)
+		if !exists {
+			v.ObjKeys = append(v.ObjKeys, key)
+		}
 		return cell, nil
 	default:
 		// TODO?
@@ -408,6 +474,15 @@ func (v *Value) Compare(b *Value) (int, error) {
 		return strings.Compare(*v.Str, *b.Str), nil
 	}
 
+	// if either side carries an exact big integer, compare exactly instead
+	// of going through float64 (which could round two distinct big
+	// integers to the same approximation)
+	if v.Tag == ValueNum && b.Tag == ValueNum && (v.BigInt != nil || b.BigInt != nil) {
+		aBig := v.asBigFloat()
+		bBig := b.asBigFloat()
+		return aBig.Cmp(bBig), nil
+	}
+
 	// coerce to num and compare
 	aNum := v.asFloat64()
 	bNum := b.asFloat64()
@@ -420,6 +495,16 @@ func (v *Value) Compare(b *Value) (int, error) {
 	}
 }
 
+// asBigFloat returns an exact big.Float representation of a ValueNum,
+// preferring BigInt when present so comparisons against other big integers
+// don't lose precision by routing through float64 first.
+func (v *Value) asBigFloat() *big.Float {
+	if v.BigInt != nil {
+		return new(big.Float).SetInt(v.BigInt)
+	}
+	return big.NewFloat(v.asFloat64())
+}
+
 func (v *Value) Not() *Value {
 	var notValue Value
 	if v.isTruthy() {
@@ -431,23 +516,111 @@ func (v *Value) Not() *Value {
 }
 
 func (v *Value) MarshalJSON() ([]byte, error) {
-	seen := make([]*Value, 0)
+	return v.MarshalJSONWithOptions(JSONEncodeOptions{EscapeHTML: true})
+}
+
+func (c *Cell) MarshalJSON() ([]byte, error) {
+	return c.Value.MarshalJSON()
+}
+
+// JSONEncodeOptions controls the output shape produced by
+// Value.MarshalJSONWithOptions, which walks the value tree directly instead
+// of going through encoding/json's map ordering, so SortKeys can be honoured
+// even though objects otherwise preserve insertion order.
+type JSONEncodeOptions struct {
+	SortKeys   bool // emit object keys in sorted order instead of insertion order
+	EscapeHTML bool // escape '<', '>' and '&' as encoding/json does by default
+}
+
+// MarshalJSONWithOptions is a thin wrapper around Encode for callers that
+// want the whole document as a single []byte (e.g. to satisfy
+// json.Marshaler). Prefer Encode directly when writing to a file or socket,
+// since this still builds the full document in memory first.
+func (v *Value) MarshalJSONWithOptions(opts JSONEncodeOptions) ([]byte, error) {
 	var buf bytes.Buffer
-	err := v.marshalAndDetectCircularReferences(&buf, seen)
-	if err != nil {
+	if err := v.Encode(&buf, EncodeOptions{SortKeys: opts.SortKeys, EscapeHTML: opts.EscapeHTML}); err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
 }
 
-func (c *Cell) MarshalJSON() ([]byte, error) {
-	return c.Value.MarshalJSON()
+// EncodeOptions controls the output Value.Encode produces.
+type EncodeOptions struct {
+	SortKeys   bool   // emit object keys in sorted order instead of insertion order
+	EscapeHTML bool   // escape '<', '>' and '&' as encoding/json does by default
+	Indent     string // "" for fully compact output; otherwise each nesting level is indented by one copy of Indent
+	NDJSON     bool   // v must be a ValueArray; each element is written on its own line instead of as one document
+}
+
+func marshalStringWithOptions(s string, escapeHTML bool) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(escapeHTML)
+	if err := enc.Encode(s); err != nil {
+		return nil, err
+	}
+	// Encoder.Encode appends a trailing newline; the caller wants just the value
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// Encode writes v to w as JSON per opts, streaming through a bufio.Writer
+// instead of building the whole document in memory the way MarshalJSON used
+// to (its array branch delegated to json.Marshal(v.Array), which recursively
+// re-buffered every nested value). Numbers are appended directly with
+// strconv.AppendFloat rather than round-tripped through json.Marshal.
+// Strings still go through encoding/json's escaping (marshalStringWithOptions)
+// rather than strconv.Quote - strconv.Quote escapes control characters as
+// \xHH, which isn't valid JSON, where encoding/json correctly emits \u00HH.
+// Circular references are caught by walking the stack of values currently
+// being encoded, the same check marshalAndDetectCircularReferences used to
+// do with its seen slice.
+func (v *Value) Encode(w io.Writer, opts EncodeOptions) error {
+	bw := bufio.NewWriter(w)
+
+	if opts.NDJSON {
+		if v.Tag != ValueArray {
+			return fmt.Errorf("ndjson output requires an array")
+		}
+		lineOpts := opts
+		lineOpts.NDJSON = false
+		lineOpts.Indent = ""
+		for i, cell := range v.Array {
+			if i > 0 {
+				if err := bw.WriteByte('\n'); err != nil {
+					return err
+				}
+			}
+			if err := cell.Value.encode(bw, nil, lineOpts, 0); err != nil {
+				return err
+			}
+		}
+		return bw.Flush()
+	}
+
+	if err := v.encode(bw, nil, opts, 0); err != nil {
+		return err
+	}
+	return bw.Flush()
 }
 
-func (v *Value) marshalAndDetectCircularReferences(w *bytes.Buffer, seen []*Value) error {
-	var b []byte
-	var err error
+// newlineIndent writes a newline followed by depth copies of indent, or
+// nothing at all when indent is "" (compact output).
+func newlineIndent(w *bufio.Writer, indent string, depth int) error {
+	if indent == "" {
+		return nil
+	}
+	if err := w.WriteByte('\n'); err != nil {
+		return err
+	}
+	for i := 0; i < depth; i++ {
+		if _, err := w.WriteString(indent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+func (v *Value) encode(w *bufio.Writer, seen []*Value, opts EncodeOptions, depth int) error {
 	for _, seenVal := range seen {
 		if isSame(seenVal, v) {
 			return fmt.Errorf("circular reference")
@@ -457,46 +630,101 @@ func (v *Value) marshalAndDetectCircularReferences(w *bytes.Buffer, seen []*Valu
 
 	switch v.Tag {
 	case ValueStr:
-		b, err = json.Marshal(v.Str)
+		b, err := marshalStringWithOptions(*v.Str, opts.EscapeHTML)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
 	case ValueBool:
-		b, err = json.Marshal(v.Bool)
+		if *v.Bool {
+			_, err := w.WriteString("true")
+			return err
+		}
+		_, err := w.WriteString("false")
+		return err
 	case ValueNum:
-		b, err = json.Marshal(v.Num)
+		if v.BigInt != nil {
+			_, err := w.WriteString(v.BigInt.String())
+			return err
+		}
+		buf := strconv.AppendFloat(make([]byte, 0, 32), *v.Num, 'f', -1, 64)
+		_, err := w.Write(buf)
+		return err
 	case ValueNil, ValueUnknown:
-		b, err = json.Marshal(nil)
+		_, err := w.WriteString("null")
+		return err
 	case ValueArray:
-		b, err = json.Marshal(v.Array)
+		if err := w.WriteByte('['); err != nil {
+			return err
+		}
+		for i, cell := range v.Array {
+			if i > 0 {
+				if err := w.WriteByte(','); err != nil {
+					return err
+				}
+			}
+			if err := newlineIndent(w, opts.Indent, depth+1); err != nil {
+				return err
+			}
+			if err := cell.Value.encode(w, seen, opts, depth+1); err != nil {
+				return err
+			}
+		}
+		if len(v.Array) > 0 {
+			if err := newlineIndent(w, opts.Indent, depth); err != nil {
+				return err
+			}
+		}
+		return w.WriteByte(']')
 	case ValueObj:
-		w.WriteString("{ ")
-		for i, key := range v.ObjKeys {
+		keys := v.ObjKeys
+		if opts.SortKeys {
+			keys = append([]string(nil), keys...)
+			sort.Strings(keys)
+		}
+
+		if err := w.WriteByte('{'); err != nil {
+			return err
+		}
+		for i, key := range keys {
 			if i > 0 {
-				w.WriteString(", ")
+				if err := w.WriteByte(','); err != nil {
+					return err
+				}
+			}
+			if err := newlineIndent(w, opts.Indent, depth+1); err != nil {
+				return err
 			}
 
-			keyJson, err := json.Marshal(key)
+			keyJson, err := marshalStringWithOptions(key, opts.EscapeHTML)
 			if err != nil {
 				return err
 			}
-
-			w.Write(keyJson)
-			w.WriteString(": ")
+			if _, err := w.Write(keyJson); err != nil {
+				return err
+			}
+			if err := w.WriteByte(':'); err != nil {
+				return err
+			}
+			if opts.Indent != "" {
+				if err := w.WriteByte(' '); err != nil {
+					return err
+				}
+			}
 
 			val := (*v.Obj)[key].Value
-			err = val.marshalAndDetectCircularReferences(w, seen)
-			if err != nil {
+			if err := val.encode(w, seen, opts, depth+1); err != nil {
 				return err
 			}
 		}
-		w.WriteString(" }")
-		return nil
+		if len(keys) > 0 {
+			if err := newlineIndent(w, opts.Indent, depth); err != nil {
+				return err
+			}
+		}
+		return w.WriteByte('}')
 	default:
 		return fmt.Errorf("unhandled tag %v", v.Tag)
 	}
-
-	if err != nil {
-		return err
-	}
-
-	w.Write(b)
-	return nil
 }
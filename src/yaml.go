@@ -0,0 +1,399 @@
+package lang
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// yamlDecoder implements a minimal YAML subset: block-style mappings and
+// sequences (2-space indentation, no tabs), plain/quoted scalars, and
+// "---" document separators. It doesn't support flow style ({}/[]),
+// multi-line scalars, anchors or tags - full YAML is a much bigger
+// surface than jqawk programs tend to need.
+type yamlDecoder struct {
+	docs []string
+	pos  int
+}
+
+func newYamlDecoder(r io.Reader) *yamlDecoder {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return &yamlDecoder{docs: nil}
+	}
+	docs := splitYamlDocs(string(b))
+	return &yamlDecoder{docs: docs}
+}
+
+func (d *yamlDecoder) Next() (Value, error) {
+	if d.pos >= len(d.docs) {
+		return Value{}, io.EOF
+	}
+	doc := d.docs[d.pos]
+	d.pos++
+	return parseYAML(doc)
+}
+
+func splitYamlDocs(src string) []string {
+	lines := strings.Split(src, "\n")
+	docs := []string{}
+	cur := make([]string, 0)
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "---" {
+			if len(cur) > 0 {
+				docs = append(docs, strings.Join(cur, "\n"))
+			}
+			cur = cur[:0]
+			continue
+		}
+		cur = append(cur, line)
+	}
+	if len(strings.TrimSpace(strings.Join(cur, ""))) > 0 {
+		docs = append(docs, strings.Join(cur, "\n"))
+	}
+	if len(docs) == 0 {
+		docs = append(docs, "")
+	}
+	return docs
+}
+
+// parseYAML parses a single YAML document's text into a Value.
+func parseYAML(src string) (Value, error) {
+	lines := yamlLines(src)
+	if len(lines) == 0 {
+		return NewValue(nil), nil
+	}
+	pos := 0
+	return parseYamlBlock(lines, &pos, lines[0].indent)
+}
+
+type yamlLine struct {
+	indent int
+	text   string
+}
+
+func yamlLines(src string) []yamlLine {
+	out := make([]yamlLine, 0)
+	for _, raw := range strings.Split(src, "\n") {
+		line := stripYamlComment(raw)
+		trimmed := strings.TrimRight(line, " \t\r")
+		content := strings.TrimLeft(trimmed, " ")
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		indent := len(trimmed) - len(content)
+		out = append(out, yamlLine{indent: indent, text: content})
+	}
+	return out
+}
+
+// stripYamlComment removes a trailing "# ..." comment that isn't inside a
+// quoted scalar. It's a best-effort pass, not a full YAML tokenizer.
+func stripYamlComment(line string) string {
+	inSingle, inDouble := false, false
+	for i, r := range line {
+		switch r {
+		case '\'':
+			if !inDouble {
+				inSingle = !inSingle
+			}
+		case '"':
+			if !inSingle {
+				inDouble = !inDouble
+			}
+		case '#':
+			if !inSingle && !inDouble && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+func isYamlSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func parseYamlBlock(lines []yamlLine, pos *int, indent int) (Value, error) {
+	if *pos >= len(lines) || lines[*pos].indent != indent {
+		return NewValue(nil), nil
+	}
+
+	if isYamlSeqItem(lines[*pos].text) {
+		return parseYamlSeq(lines, pos, indent)
+	}
+	return parseYamlMap(lines, pos, indent)
+}
+
+func parseYamlSeq(lines []yamlLine, pos *int, indent int) (Value, error) {
+	arr := NewArray()
+	for *pos < len(lines) && lines[*pos].indent == indent && isYamlSeqItem(lines[*pos].text) {
+		item := strings.TrimSpace(strings.TrimPrefix(lines[*pos].text, "-"))
+		*pos++
+
+		if item == "" {
+			if *pos < len(lines) && lines[*pos].indent > indent {
+				child, err := parseYamlBlock(lines, pos, lines[*pos].indent)
+				if err != nil {
+					return Value{}, err
+				}
+				arr.Array = append(arr.Array, NewCell(child))
+			} else {
+				arr.Array = append(arr.Array, NewCell(NewValue(nil)))
+			}
+			continue
+		}
+
+		if key, rest, ok := splitYamlKeyValue(item); ok {
+			// "- key: value" starts an inline mapping; sibling keys line up
+			// two columns past the dash.
+			obj := NewObject()
+			assignYamlKey(&obj, key, rest)
+			childIndent := indent + 2
+			for *pos < len(lines) && lines[*pos].indent == childIndent && !isYamlSeqItem(lines[*pos].text) {
+				k, r, ok := splitYamlKeyValue(lines[*pos].text)
+				if !ok {
+					break
+				}
+				*pos++
+				if r == "" && *pos < len(lines) && lines[*pos].indent > childIndent {
+					child, err := parseYamlBlock(lines, pos, lines[*pos].indent)
+					if err != nil {
+						return Value{}, err
+					}
+					setYamlObjField(&obj, k, child)
+				} else {
+					assignYamlKey(&obj, k, r)
+				}
+			}
+			arr.Array = append(arr.Array, NewCell(obj))
+			continue
+		}
+
+		arr.Array = append(arr.Array, NewCell(parseYamlScalar(item)))
+	}
+	return arr, nil
+}
+
+func parseYamlMap(lines []yamlLine, pos *int, indent int) (Value, error) {
+	obj := NewObject()
+	for *pos < len(lines) && lines[*pos].indent == indent && !isYamlSeqItem(lines[*pos].text) {
+		key, rest, ok := splitYamlKeyValue(lines[*pos].text)
+		if !ok {
+			return Value{}, fmt.Errorf("expected \"key: value\", got %q", lines[*pos].text)
+		}
+		*pos++
+
+		if rest == "" {
+			if *pos < len(lines) && lines[*pos].indent > indent {
+				child, err := parseYamlBlock(lines, pos, lines[*pos].indent)
+				if err != nil {
+					return Value{}, err
+				}
+				setYamlObjField(&obj, key, child)
+				continue
+			}
+			setYamlObjField(&obj, key, NewValue(nil))
+			continue
+		}
+
+		assignYamlKey(&obj, key, rest)
+	}
+	return obj, nil
+}
+
+func assignYamlKey(obj *Value, key, rawValue string) {
+	setYamlObjField(obj, key, parseYamlScalar(rawValue))
+}
+
+func setYamlObjField(obj *Value, key string, val Value) {
+	if _, exists := (*obj.Obj)[key]; !exists {
+		obj.ObjKeys = append(obj.ObjKeys, key)
+	}
+	(*obj.Obj)[key] = NewCell(val)
+}
+
+// splitYamlKeyValue splits "key: value" (or bare "key:") on the first colon
+// that isn't inside a quoted key.
+func splitYamlKeyValue(text string) (key string, value string, ok bool) {
+	inSingle, inDouble := false, false
+	for i, r := range text {
+		switch r {
+		case '\'':
+			if !inDouble {
+				inSingle = !inSingle
+			}
+		case '"':
+			if !inSingle {
+				inDouble = !inDouble
+			}
+		case ':':
+			if inSingle || inDouble {
+				continue
+			}
+			if i+1 == len(text) || text[i+1] == ' ' {
+				key = strings.TrimSpace(unquoteYamlScalar(strings.TrimSpace(text[:i])))
+				value = strings.TrimSpace(text[i+1:])
+				return key, value, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+func unquoteYamlScalar(s string) string {
+	if len(s) >= 2 {
+		if (s[0] == '"' && s[len(s)-1] == '"') || (s[0] == '\'' && s[len(s)-1] == '\'') {
+			return s[1 : len(s)-1]
+		}
+	}
+	return s
+}
+
+func parseYamlScalar(text string) Value {
+	text = strings.TrimSpace(text)
+	if len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"' {
+		return NewValue(text[1 : len(text)-1])
+	}
+	if len(text) >= 2 && text[0] == '\'' && text[len(text)-1] == '\'' {
+		return NewValue(strings.ReplaceAll(text[1:len(text)-1], "''", "'"))
+	}
+
+	switch text {
+	case "", "~", "null", "Null", "NULL":
+		return NewValue(nil)
+	case "true", "True", "TRUE":
+		return NewValue(true)
+	case "false", "False", "FALSE":
+		return NewValue(false)
+	}
+
+	if n, err := strconv.ParseFloat(text, 64); err == nil {
+		return NewValue(n)
+	}
+
+	return NewValue(text)
+}
+
+// encodeYAML renders v as YAML text in the block-style subset parseYAML
+// accepts, except that an empty array or object is written in flow style
+// (`[]` / `{}`), which parseYAML reads back as a plain string.
+func encodeYAML(v *Value) (string, error) {
+	var sb strings.Builder
+	if err := writeYamlValue(&sb, v, 0, false); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+func writeYamlValue(sb *strings.Builder, v *Value, indent int, inline bool) error {
+	pad := strings.Repeat("  ", indent)
+
+	switch v.Tag {
+	case ValueArray:
+		if len(v.Array) == 0 {
+			sb.WriteString("[]\n")
+			return nil
+		}
+		if inline {
+			sb.WriteString("\n")
+		}
+		for _, cell := range v.Array {
+			item := cell.Value
+			if item.Tag == ValueArray || item.Tag == ValueObj {
+				sb.WriteString(pad)
+				sb.WriteString("-")
+				if err := writeYamlValue(sb, &item, indent+1, true); err != nil {
+					return err
+				}
+			} else {
+				sb.WriteString(pad)
+				sb.WriteString("- ")
+				sb.WriteString(formatYamlScalar(&item))
+				sb.WriteString("\n")
+			}
+		}
+		return nil
+	case ValueObj:
+		if len(v.ObjKeys) == 0 {
+			sb.WriteString("{}\n")
+			return nil
+		}
+		if inline {
+			sb.WriteString("\n")
+		}
+		for _, key := range v.ObjKeys {
+			field := (*v.Obj)[key].Value
+			sb.WriteString(pad)
+			sb.WriteString(formatYamlKey(key))
+			sb.WriteString(":")
+			if field.Tag == ValueArray || field.Tag == ValueObj {
+				if err := writeYamlValue(sb, &field, indent+1, true); err != nil {
+					return err
+				}
+			} else {
+				sb.WriteString(" ")
+				sb.WriteString(formatYamlScalar(&field))
+				sb.WriteString("\n")
+			}
+		}
+		return nil
+	default:
+		sb.WriteString(pad)
+		sb.WriteString(formatYamlScalar(v))
+		sb.WriteString("\n")
+		return nil
+	}
+}
+
+func formatYamlKey(key string) string {
+	if yamlScalarNeedsQuoting(key) {
+		return strconv.Quote(key)
+	}
+	return key
+}
+
+func formatYamlScalar(v *Value) string {
+	switch v.Tag {
+	case ValueNil:
+		return "null"
+	case ValueBool:
+		if *v.Bool {
+			return "true"
+		}
+		return "false"
+	case ValueNum:
+		return v.String()
+	case ValueStr:
+		if yamlScalarNeedsQuoting(*v.Str) {
+			return strconv.Quote(*v.Str)
+		}
+		return *v.Str
+	default:
+		return v.String()
+	}
+}
+
+func yamlScalarNeedsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	switch s {
+	case "~", "null", "Null", "NULL", "true", "True", "TRUE", "false", "False", "FALSE":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if strings.TrimSpace(s) != s {
+		return true
+	}
+	for _, r := range s {
+		switch r {
+		case ':', '#', '\'', '"', '\n', '-', '[', ']', '{', '}', ',', '&', '*', '!', '|', '>', '%', '@', '`':
+			return true
+		}
+	}
+	return false
+}